@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type noActiveAlertError struct {
+	testId string
+}
+
+func (err *noActiveAlertError) Error() string {
+	return fmt.Sprintf("No active alert for `%v`", err.testId)
+}
+
+// GetActiveAcknowledgement returns the acknowledgement for the test if it has not expired and the check has
+// not recovered since, or nil.
+func GetActiveAcknowledgement(testId string) *Alert {
+	alert, err := GetLatestAlert(testId, alertStateAcknowledged, alertStateResolved)
+	if err != nil {
+		Error("Failed to get acknowledgement for `", testId, "`: ", err)
+
+		return nil
+	}
+	if alert == nil || alert.State != alertStateAcknowledged {
+		return nil
+	}
+	if alert.ExpiresAt != nil && !alert.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+
+	return alert
+}
+
+// AcknowledgeAlert records who acknowledged the active alert for the test, stopping resends and escalation
+// until the check recovers or, if expiresMinutes is set, the acknowledgement expires.
+func AcknowledgeAlert(testId string, acknowledgedBy string, comment string, expiresMinutes time.Duration) (*Alert, error) {
+	since := time.Now()
+	subject := testId
+	if state := getAlertState(testId); state != nil {
+		since = state.Since
+		subject = state.Subject
+	} else {
+		latest, err := GetLatestAlert(testId, alertStateFiring, alertStateResolved)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || latest.State != alertStateFiring {
+			return nil, &noActiveAlertError{testId: testId}
+		}
+		since = latest.Timestamp
+	}
+
+	alert := &Alert{
+		AlertId:        testId,
+		State:          alertStateAcknowledged,
+		AcknowledgedBy: acknowledgedBy,
+		Comment:        comment,
+	}
+	if expiresMinutes > 0 {
+		expiresAt := time.Now().Add(expiresMinutes * time.Minute)
+		alert.ExpiresAt = &expiresAt
+	}
+	err := alert.Save()
+	if err != nil {
+		return nil, err
+	}
+	markAcknowledged(testId, subject, since, alert.ExpiresAt)
+	Info(fmt.Sprintf("ACKNOWLEDGED - %v by %v", subject, acknowledgedBy))
+
+	return alert, nil
+}
@@ -1,9 +1,60 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"time"
 )
 
-func AlertSimplePush(subject string, message string) {
-	httpClient.R().Get(fmt.Sprintf("https://api.simplepush.io/send/%s/%s/%s", config.Alerts.SimplePush.Code, subject, message))
+type AlertDetail struct {
+	Name  string
+	Value string
+}
+
+// alertTemplateData is what alert channels render their messages from.
+type alertTemplateData struct {
+	Subject   string
+	Message   string
+	TestId    string
+	Severity  string
+	Passed    bool
+	Details   []AlertDetail
+	Timestamp time.Time
+}
+
+func newAlertTemplateData(checkResult CheckResult, subject string, message string) alertTemplateData {
+	data := alertTemplateData{
+		Subject:   subject,
+		Message:   message,
+		Timestamp: time.Now(),
+	}
+	if checkResult != nil {
+		data.TestId = checkResult.GetTestId()
+		data.Severity = checkResult.GetSeverityName()
+		data.Passed = checkResult.HasPassed()
+		data.Details = checkResult.GetDetails()
+		if !checkResult.GetTimestamp().IsZero() {
+			data.Timestamp = checkResult.GetTimestamp()
+		}
+	}
+
+	return data
+}
+
+func AlertSimplePush(simplePushConfig SimplePushAlert, subject string, message string) (int, error) {
+	response, err := httpClient.R().Get(fmt.Sprintf(
+		"https://api.simplepush.io/send/%s/%s/%s",
+		url.PathEscape(simplePushConfig.Code),
+		url.PathEscape(subject),
+		url.PathEscape(message),
+	))
+	if err != nil {
+		return 0, errors.New("SimplePush request failed: " + err.Error())
+	}
+	if response.StatusCode() >= 400 {
+		return response.StatusCode(), errors.New(fmt.Sprintf("SimplePush responded with %v: %v", response.Status(), response.String()))
+	}
+
+	return response.StatusCode(), nil
 }
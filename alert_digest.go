@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultGroupingWindow = 30 * time.Second
+const suppressedByServerDown = "server not connected"
+const suppressedByDependency = "dependency"
+
+// pendingAlert is an alert that has passed every check and is ready to be sent.
+type pendingAlert struct {
+	checkResult     CheckResult
+	subject         string
+	message         string
+	notifiers       []Notifier
+	escalationLevel int
+	consequenceOf   string
+}
+
+// alertDigest collects the alerts for one server, or server group, raised within the grouping window.
+type alertDigest struct {
+	name   string
+	alerts []pendingAlert
+	timer  *time.Timer
+}
+
+var alertDigests = make(map[string]*alertDigest)
+var alertDigestsLock sync.Mutex
+
+func (grouping *AlertGroupingConfig) GetWindow() time.Duration {
+	if grouping.WindowSeconds <= 0 {
+		return defaultGroupingWindow
+	}
+
+	return grouping.WindowSeconds * time.Second
+}
+
+// alertDigestKey returns the digest a server check alert is grouped into, or an empty string if it should
+// be sent straight away. Website alerts and always severe alerts are never grouped.
+func alertDigestKey(checkResult CheckResult) string {
+	serverCheck, ok := checkResult.(*ServerCheck)
+	if !ok || !config.Alerts.Grouping.Enabled || serverCheck.Server == nil || serverCheck.IsAlwaysSevere() {
+		return ""
+	}
+
+	key := serverCheck.Server.Name
+	if config.Alerts.Grouping.ByGroup && serverCheck.Check != nil && serverCheck.Check.groupName != "" {
+		key += ":" + serverCheck.Check.groupName
+	}
+
+	return key
+}
+
+// queueAlertDigest adds the alert to its digest, starting the grouping window if it is the first one. A
+// repeat alert for the same test replaces the queued one.
+func queueAlertDigest(key string, pending pendingAlert) {
+	alertDigestsLock.Lock()
+	defer alertDigestsLock.Unlock()
+
+	digest, ok := alertDigests[key]
+	if !ok {
+		digest = &alertDigest{
+			name:   key,
+			alerts: make([]pendingAlert, 0),
+		}
+		alertDigests[key] = digest
+		pendingAlerts.Add(1)
+		digest.timer = time.AfterFunc(config.Alerts.Grouping.GetWindow(), func() {
+			flushAlertDigest(key)
+		})
+	}
+	for i, queued := range digest.alerts {
+		if queued.checkResult.GetTestId() == pending.checkResult.GetTestId() {
+			digest.alerts[i] = pending
+			return
+		}
+	}
+	digest.alerts = append(digest.alerts, pending)
+}
+
+func (digest *alertDigest) holds(testId string) bool {
+	for _, queued := range digest.alerts {
+		if queued.checkResult.GetTestId() == testId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAlertQueued(testId string) bool {
+	alertDigestsLock.Lock()
+	defer alertDigestsLock.Unlock()
+
+	for _, digest := range alertDigests {
+		if digest.holds(testId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dequeueAlert drops a queued alert for a check that recovered before its digest was sent.
+func dequeueAlert(testId string) {
+	alertDigestsLock.Lock()
+	defer alertDigestsLock.Unlock()
+
+	for _, digest := range alertDigests {
+		for i, queued := range digest.alerts {
+			if queued.checkResult.GetTestId() == testId {
+				digest.alerts = append(digest.alerts[:i], digest.alerts[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// flushAlertDigests sends every queued digest without waiting for its window to end.
+func flushAlertDigests() {
+	alertDigestsLock.Lock()
+	keys := make([]string, 0, len(alertDigests))
+	for key := range alertDigests {
+		keys = append(keys, key)
+	}
+	alertDigestsLock.Unlock()
+
+	for _, key := range keys {
+		flushAlertDigest(key)
+	}
+}
+
+func flushAlertDigest(key string) {
+	alertDigestsLock.Lock()
+	digest, ok := alertDigests[key]
+	if ok {
+		delete(alertDigests, key)
+		digest.timer.Stop()
+	}
+	alertDigestsLock.Unlock()
+	if !ok {
+		return
+	}
+	defer pendingAlerts.Done()
+
+	sendAlertDigest(digest)
+}
+
+// isServerDown reports whether the server a check ran on is alerting, or about to alert, as not connected,
+// in which case the check's own alert would only repeat it. A digest being sent is no longer queued, so it is
+// passed in to check for a connection alert sent along with the check.
+func isServerDown(checkResult CheckResult, digest *alertDigest) bool {
+	serverCheck, ok := checkResult.(*ServerCheck)
+	if !ok || serverCheck.Server == nil || serverCheck.Check == nil || serverCheck.IsAlwaysSevere() {
+		return false
+	}
+
+	connectionTestId := getConnectionTestId(checkResult)
+	if digest != nil && digest.holds(connectionTestId) {
+		return true
+	}
+
+	return getAlertState(connectionTestId) != nil || isAlertQueued(connectionTestId)
+}
+
+// getConnectionTestId returns the test id of the connection to the server a check ran on, or an empty string
+// for website checks.
+func getConnectionTestId(checkResult CheckResult) string {
+	serverCheck, ok := checkResult.(*ServerCheck)
+	if !ok || serverCheck.Server == nil {
+		return ""
+	}
+
+	return (&ServerCheck{Server: serverCheck.Server}).GetTestId()
+}
+
+// sendAlertDigest sends the digest's alerts as one notification, dropping check alerts for a server that is
+// not connected. A digest holding a single alert is sent as a normal alert.
+func sendAlertDigest(digest *alertDigest) {
+	alerts := make([]pendingAlert, 0, len(digest.alerts))
+	for _, pending := range digest.alerts {
+		if isServerDown(pending.checkResult, digest) {
+			Warn(fmt.Sprintf("%v ALERT - %v (suppressed by %v)", pending.checkResult.GetSeverityName(), pending.subject, suppressedByServerDown))
+			saveSuppressedAlert(pending.checkResult, suppressedByServerDown, getConnectionTestId(pending.checkResult))
+			continue
+		}
+		alerts = append(alerts, pending)
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	if len(alerts) == 1 {
+		deliverAlert(alerts[0])
+		return
+	}
+
+	channelNames := make([]string, 0)
+	lines := make([]string, 0, len(alerts))
+	for _, pending := range alerts {
+		for _, notifier := range pending.notifiers {
+			if !containsString(channelNames, notifier.Name()) {
+				channelNames = append(channelNames, notifier.Name())
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%v: %v", pending.subject, pending.message))
+	}
+	subject := fmt.Sprintf("%v (%d alerts)", digest.name, len(alerts))
+	message := strings.Join(lines, "\n")
+	Error(fmt.Sprintf("ALERT DIGEST - %v", subject))
+
+	deliveries := notify(eligibleNotifiersByName(channelNames), alerts[0].checkResult, subject, message)
+	for _, pending := range alerts {
+		recordAlert(pending, deliveries)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestIsServerDownWithConnectionAlertInDigest(t *testing.T) {
+	server := &ServerConfig{Name: "Web Server"}
+	check := &Check{Name: "disk"}
+	checkAlert := pendingAlert{checkResult: &ServerCheck{Server: server, Check: check}}
+	digest := &alertDigest{
+		name:   server.Name,
+		alerts: []pendingAlert{checkAlert},
+	}
+	if isServerDown(checkAlert.checkResult, digest) {
+		t.Fatalf("expected the server to be up without a connection alert")
+	}
+
+	digest.alerts = append(digest.alerts, pendingAlert{checkResult: &ServerCheck{Server: server}})
+	if !isServerDown(checkAlert.checkResult, digest) {
+		t.Fatalf("expected the connection alert in the digest to mark the server as down")
+	}
+	if isServerDown(digest.alerts[1].checkResult, digest) {
+		t.Fatalf("expected the connection alert itself not to be suppressed")
+	}
+	if isServerDown(&ServerCheck{Server: server, Check: check, AlwaysSevere: true}, digest) {
+		t.Fatalf("expected always severe alerts not to be suppressed")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	emailSecurityNone     = "none"
+	emailSecurityStartTls = "starttls"
+	emailSecurityTls      = "tls"
+)
+
+var emailTextTemplate = template.Must(template.New("email.txt").Parse(`{{.Subject}}
+
+{{.Message}}
+{{range .Details}}
+{{.Name}}: {{.Value}}{{end}}
+
+Test: {{.TestId}}
+Severity: {{.Severity}}
+Time: {{.Timestamp.Format "2006-01-02 15:04:05 MST"}}
+`))
+
+var emailHtmlTemplate = htmlTemplate.Must(htmlTemplate.New("email.html").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+	<h2>{{.Subject}}</h2>
+	<p>{{.Message}}</p>
+	<table cellpadding="4" style="border-collapse: collapse;">
+		{{range .Details}}<tr><th align="left" valign="top">{{.Name}}</th><td><pre style="margin: 0; white-space: pre-wrap;">{{.Value}}</pre></td></tr>
+		{{end}}<tr><th align="left">Test</th><td>{{.TestId}}</td></tr>
+		<tr><th align="left">Severity</th><td>{{.Severity}}</td></tr>
+		<tr><th align="left">Time</th><td>{{.Timestamp.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+	</table>
+</body>
+</html>
+`))
+
+func AlertEmail(emailConfig EmailAlert, checkResult CheckResult, subject string, message string) error {
+	recipients := make([]string, 0)
+	recipients = append(recipients, emailConfig.To...)
+	recipients = append(recipients, emailConfig.Cc...)
+	recipients = append(recipients, emailConfig.Bcc...)
+	if len(recipients) == 0 {
+		return errors.New("No email recipients configured")
+	}
+
+	body, err := buildEmail(emailConfig, newAlertTemplateData(checkResult, subject, message))
+	if err != nil {
+		return errors.New("Could not build email: " + err.Error())
+	}
+
+	client, err := dialSmtp(emailConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if emailConfig.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", emailConfig.Username, emailConfig.Password, emailConfig.Host))
+		if err != nil {
+			return errors.New("Could not authenticate with SMTP server: " + err.Error())
+		}
+	}
+	if err = client.Mail(emailAddress(emailConfig.From)); err != nil {
+		return errors.New("SMTP server rejected sender: " + err.Error())
+	}
+	for _, recipient := range recipients {
+		if err = client.Rcpt(emailAddress(recipient)); err != nil {
+			return errors.New(fmt.Sprintf("SMTP server rejected recipient `%v`: %v", recipient, err))
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return errors.New("Could not start email body: " + err.Error())
+	}
+	if _, err = writer.Write(body); err != nil {
+		return errors.New("Could not write email body: " + err.Error())
+	}
+	if err = writer.Close(); err != nil {
+		return errors.New("SMTP server rejected email: " + err.Error())
+	}
+
+	return client.Quit()
+}
+
+// emailAddress strips any display name, e.g. "Monitor <monitor@example.com>", for the SMTP envelope.
+func emailAddress(value string) string {
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return value
+	}
+
+	return address.Address
+}
+
+func dialSmtp(emailConfig EmailAlert) (*smtp.Client, error) {
+	port := emailConfig.Port
+	if port == 0 {
+		port = 587
+		if emailConfig.Security == emailSecurityTls {
+			port = 465
+		}
+	}
+	address := net.JoinHostPort(emailConfig.Host, fmt.Sprintf("%d", port))
+	tlsConfig := &tls.Config{
+		ServerName: emailConfig.Host,
+	}
+
+	var client *smtp.Client
+	switch emailConfig.Security {
+	case emailSecurityTls:
+		connection, err := tls.Dial("tcp", address, tlsConfig)
+		if err != nil {
+			return nil, errors.New("Could not connect to SMTP server: " + err.Error())
+		}
+		client, err = smtp.NewClient(connection, emailConfig.Host)
+		if err != nil {
+			connection.Close()
+			return nil, errors.New("Could not start SMTP session: " + err.Error())
+		}
+	case "", emailSecurityStartTls, emailSecurityNone:
+		var err error
+		client, err = smtp.Dial(address)
+		if err != nil {
+			return nil, errors.New("Could not connect to SMTP server: " + err.Error())
+		}
+		if emailConfig.Security != emailSecurityNone {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, errors.New("Could not start TLS with SMTP server: " + err.Error())
+			}
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown email security `%v`", emailConfig.Security))
+	}
+
+	return client, nil
+}
+
+// buildEmail renders a multipart/alternative message with plain text and HTML bodies.
+func buildEmail(emailConfig EmailAlert, data alertTemplateData) ([]byte, error) {
+	var textBody, htmlBody bytes.Buffer
+	if err := emailTextTemplate.Execute(&textBody, data); err != nil {
+		return nil, err
+	}
+	if err := emailHtmlTemplate.Execute(&htmlBody, data); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	parts := multipart.NewWriter(&message)
+	headers := []string{
+		"From: " + emailConfig.From,
+		"To: " + strings.Join(emailConfig.To, ", "),
+	}
+	if len(emailConfig.Cc) > 0 {
+		headers = append(headers, "Cc: "+strings.Join(emailConfig.Cc, ", "))
+	}
+	headers = append(headers,
+		"Subject: "+mime.QEncoding.Encode("utf-8", data.Subject),
+		"Date: "+time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary="+parts.Boundary(),
+	)
+	message.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/plain; charset=utf-8", textBody.Bytes()},
+		{"text/html; charset=utf-8", htmlBody.Bytes()},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = writer.Write(part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return message.Bytes(), nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxRestoredAlerts is how many of the latest alerts are read back on startup to find those still open.
+const maxRestoredAlerts = 10000
+
+const (
+	alertStateFiring       = "firing"
+	alertStateResolved     = "resolved"
+	alertStateAcknowledged = "acknowledged"
+)
+
+// alertState tracks a check that has alerted and not yet recovered.
+type alertState struct {
+	Since           time.Time
+	Subject         string
+	Channels        []string
+	EscalationLevel int
+	Acknowledged    bool
+	AckExpiresAt    *time.Time
+}
+
+// IsAcknowledged reports whether the alert has been acknowledged and the acknowledgement has not expired.
+func (state *alertState) IsAcknowledged() bool {
+	if !state.Acknowledged {
+		return false
+	}
+
+	return state.AckExpiresAt == nil || state.AckExpiresAt.After(time.Now())
+}
+
+var alertStates = make(map[string]*alertState)
+var alertStatesLock sync.Mutex
+
+func getAlertState(testId string) *alertState {
+	alertStatesLock.Lock()
+	defer alertStatesLock.Unlock()
+
+	if state, ok := alertStates[testId]; ok {
+		stateCopy := *state
+		return &stateCopy
+	}
+
+	return nil
+}
+
+// markAlerting records that an alert went out for the test, keeping the original start time and adding any
+// channels not already notified.
+func markAlerting(testId string, subject string, channels []string, escalationLevel int) {
+	alertStatesLock.Lock()
+	defer alertStatesLock.Unlock()
+
+	state, ok := alertStates[testId]
+	if !ok {
+		state = &alertState{
+			Since:           time.Now(),
+			Subject:         subject,
+			Channels:        make([]string, 0),
+			EscalationLevel: -1,
+		}
+		alertStates[testId] = state
+	}
+	if escalationLevel > state.EscalationLevel {
+		state.EscalationLevel = escalationLevel
+	}
+	for _, channel := range channels {
+		if !containsString(state.Channels, channel) {
+			state.Channels = append(state.Channels, channel)
+		}
+	}
+}
+
+// markAcknowledged flags the test as acknowledged, tracking it from the given time if no alert is held in
+// memory so that its recovery is still recorded.
+func markAcknowledged(testId string, subject string, since time.Time, expiresAt *time.Time) {
+	alertStatesLock.Lock()
+	defer alertStatesLock.Unlock()
+
+	state, ok := alertStates[testId]
+	if !ok {
+		state = &alertState{
+			Since:           since,
+			Subject:         subject,
+			Channels:        make([]string, 0),
+			EscalationLevel: -1,
+		}
+		alertStates[testId] = state
+	}
+	state.Acknowledged = true
+	state.AckExpiresAt = expiresAt
+}
+
+func clearAlerting(testId string) *alertState {
+	alertStatesLock.Lock()
+	defer alertStatesLock.Unlock()
+
+	state, ok := alertStates[testId]
+	if !ok {
+		return nil
+	}
+	delete(alertStates, testId)
+
+	return state
+}
+
+// RestoreAlertStates rebuilds the alerts that were still open when the monitor last stopped from the alert
+// index, so checks that recover after a restart are resolved, and their acknowledgements closed, rather than
+// left firing.
+func RestoreAlertStates() {
+	alerts, err := GetRecentAlerts(maxRestoredAlerts, alertStateFiring, alertStateAcknowledged, alertStateResolved)
+	if err != nil {
+		Error("Could not restore open alerts: ", err)
+		return
+	}
+
+	restored := openAlertStates(*alerts)
+	alertStatesLock.Lock()
+	defer alertStatesLock.Unlock()
+
+	for testId, state := range restored {
+		if _, ok := alertStates[testId]; !ok {
+			alertStates[testId] = state
+		}
+	}
+	if len(restored) > 0 {
+		Info("Restored ", len(restored), " open alerts")
+	}
+}
+
+// openAlertStates replays alerts, oldest first, into the alerts left open at the end.
+func openAlertStates(alerts []Alert) map[string]*alertState {
+	states := make(map[string]*alertState)
+	for _, alert := range alerts {
+		if alert.State == alertStateResolved {
+			delete(states, alert.AlertId)
+			continue
+		}
+
+		state, ok := states[alert.AlertId]
+		if !ok {
+			state = &alertState{
+				Since:           alert.Timestamp,
+				Subject:         alert.AlertId,
+				Channels:        make([]string, 0),
+				EscalationLevel: -1,
+			}
+			states[alert.AlertId] = state
+		}
+		if alert.State == alertStateAcknowledged {
+			state.Acknowledged = true
+			state.AckExpiresAt = alert.ExpiresAt
+			continue
+		}
+		if alert.Subject != "" {
+			state.Subject = alert.Subject
+		}
+		if alert.EscalationLevel > state.EscalationLevel {
+			state.EscalationLevel = alert.EscalationLevel
+		}
+		for _, channel := range deliveredChannels(alert.Deliveries) {
+			if !containsString(state.Channels, channel) {
+				state.Channels = append(state.Channels, channel)
+			}
+		}
+	}
+
+	return states
+}
+
+func containsString(values []string, value string) bool {
+	for _, entry := range values {
+		if entry == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveAlertsAsync sends a resolved notification in the background if the passing check was alerting.
+func ResolveAlertsAsync(checkResult CheckResult) {
+	dequeueAlert(checkResult.GetTestId())
+	if getAlertState(checkResult.GetTestId()) == nil {
+		return
+	}
+
+	pendingAlerts.Add(1)
+	go func() {
+		defer pendingAlerts.Done()
+		ResolveAlerts(checkResult)
+	}()
+}
+
+// ResolveAlerts notifies the channels that were alerted for a check that has since recovered, and records
+// the recovery in the alert index.
+func ResolveAlerts(checkResult CheckResult) {
+	state := clearAlerting(checkResult.GetTestId())
+	if state == nil {
+		return
+	}
+
+	outage := time.Since(state.Since)
+	subject := fmt.Sprintf("RESOLVED - %v", state.Subject)
+	message := fmt.Sprintf("Recovered after %v", outage.Round(time.Second))
+	Info(fmt.Sprintf("%v RESOLVED - %v", checkResult.GetSeverityName(), state.Subject))
+
+	channels := make([]Notifier, 0)
+	for _, channel := range state.Channels {
+		if notifier, ok := notifiers[channel]; ok {
+			channels = append(channels, notifier)
+		}
+	}
+	deliveries := notify(channels, checkResult, subject, message)
+
+	alert := &Alert{
+		AlertId:       checkResult.GetTestId(),
+		State:         alertStateResolved,
+		OutageSeconds: outage.Seconds(),
+		Deliveries:    deliveries,
+	}
+	err := alert.Save()
+	if err != nil {
+		Error("Could not save alert: ", err)
+	}
+}
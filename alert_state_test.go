@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOpenAlertStates(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	delivered := []AlertDelivery{{Channel: "email", Success: true}}
+	alerts := []Alert{
+		{AlertId: "Web:disk", State: alertStateFiring, Subject: "Web (disk)", Deliveries: delivered, Timestamp: start},
+		{AlertId: "Web:disk", State: alertStateResolved, Timestamp: start.Add(time.Minute)},
+		{AlertId: "Web:load", State: alertStateFiring, Subject: "Web (load)", Deliveries: delivered, Timestamp: start.Add(2 * time.Minute)},
+		{AlertId: "Web:load", State: alertStateFiring, EscalationLevel: 1, Deliveries: []AlertDelivery{{Channel: "sms", Success: false}}, Timestamp: start.Add(3 * time.Minute)},
+		{AlertId: "Web:-", State: alertStateResolved, Timestamp: start.Add(4 * time.Minute)},
+	}
+
+	states := openAlertStates(alerts)
+	if len(states) != 1 {
+		t.Fatalf("expected 1 open alert, got %d", len(states))
+	}
+	state, ok := states["Web:load"]
+	if !ok {
+		t.Fatalf("expected `Web:load` to be open")
+	}
+	if !state.Since.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("since %v, expected the first firing alert", state.Since)
+	}
+	if state.Subject != "Web (load)" {
+		t.Errorf("subject %q, expected the alert subject", state.Subject)
+	}
+	if state.EscalationLevel != 1 {
+		t.Errorf("escalation level %d, expected 1", state.EscalationLevel)
+	}
+	if len(state.Channels) != 1 || state.Channels[0] != "email" {
+		t.Errorf("channels %v, expected only the delivered channel", state.Channels)
+	}
+}
+
+func TestOpenAlertStatesAcknowledged(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := start.Add(time.Hour)
+	alerts := []Alert{
+		{AlertId: "Web:disk", State: alertStateFiring, Subject: "Web (disk)", Timestamp: start},
+		{AlertId: "Web:disk", State: alertStateAcknowledged, AcknowledgedBy: "ops", ExpiresAt: &expiresAt, Timestamp: start.Add(time.Minute)},
+		{AlertId: "Web:load", State: alertStateAcknowledged, AcknowledgedBy: "ops", Timestamp: start.Add(2 * time.Minute)},
+		{AlertId: "Web:load", State: alertStateResolved, Timestamp: start.Add(3 * time.Minute)},
+	}
+
+	states := openAlertStates(alerts)
+	if _, ok := states["Web:load"]; ok {
+		t.Errorf("expected the resolved acknowledgement to be closed")
+	}
+	state, ok := states["Web:disk"]
+	if !ok {
+		t.Fatalf("expected `Web:disk` to be open")
+	}
+	if !state.Acknowledged || state.AckExpiresAt == nil || !state.AckExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected the acknowledgement and its expiry to be restored, got %+v", state)
+	}
+	if state.Subject != "Web (disk)" {
+		t.Errorf("subject %q, expected the firing alert subject", state.Subject)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const defaultWebhookBody = `{"subject": {{json .Subject}}, "message": {{json .Message}}, "testId": {{json .TestId}}, ` +
+	`"severity": {{json .Severity}}, "passed": {{json .Passed}}, "timestamp": {{json .Timestamp}}}`
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		bytes, err := json.Marshal(value)
+
+		return string(bytes), err
+	},
+}
+
+func parseWebhookBody(name string, webhook WebhookAlert) (*template.Template, error) {
+	body := webhook.Body
+	if body == "" {
+		body = defaultWebhookBody
+	}
+
+	return template.New(name).Funcs(webhookTemplateFuncs).Parse(body)
+}
+
+func AlertWebhook(name string, webhook WebhookAlert, checkResult CheckResult, subject string, message string) (int, error) {
+	bodyTemplate, err := parseWebhookBody(name, webhook)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Could not parse body for webhook `%v`: %v", name, err))
+	}
+	var body bytes.Buffer
+	err = bodyTemplate.Execute(&body, newAlertTemplateData(checkResult, subject, message))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Could not render body for webhook `%v`: %v", name, err))
+	}
+
+	method := strings.ToUpper(webhook.Method)
+	if method == "" {
+		method = "POST"
+	}
+	request := httpClient.R()
+	hasContentType := false
+	for header, value := range webhook.Headers {
+		request.SetHeader(header, value)
+		if strings.EqualFold(header, "Content-Type") {
+			hasContentType = true
+		}
+	}
+	if !hasContentType {
+		request.SetHeader("Content-Type", "application/json")
+	}
+	if method != "GET" {
+		request.SetBody(body.String())
+	}
+
+	response, err := request.Execute(method, webhook.Url)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Webhook `%v` request failed: %v", name, err))
+	}
+	if response.StatusCode() >= 400 {
+		return response.StatusCode(), errors.New(fmt.Sprintf("Webhook `%v` responded with %v: %v", name, response.Status(), response.String()))
+	}
+
+	return response.StatusCode(), nil
+}
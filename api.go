@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const defaultApiHistory = 24 * time.Hour
+const defaultApiResultSize = 100
+const maxApiResultSize = 1000
+
+// apiCheck is a check as exposed by the API. Commands are left out as they can hold credentials.
+type apiCheck struct {
+	TestId          string   `json:"testId,omitempty"`
+	Name            string   `json:"name"`
+	Server          string   `json:"server,omitempty"`
+	Group           string   `json:"group,omitempty"`
+	Severity        string   `json:"severity"`
+	IntervalSeconds float64  `json:"intervalSeconds"`
+	TimeoutSeconds  float64  `json:"timeoutSeconds"`
+	DependsOn       []string `json:"dependsOn,omitempty"`
+}
+
+type apiServer struct {
+	Name            string     `json:"name"`
+	Host            string     `json:"host"`
+	Port            int16      `json:"port"`
+	Severity        string     `json:"severity"`
+	IntervalSeconds float64    `json:"intervalSeconds"`
+	Groups          []string   `json:"groups"`
+	Connected       bool       `json:"connected"`
+	Checks          []apiCheck `json:"checks"`
+}
+
+type apiGroup struct {
+	Name   string     `json:"name"`
+	Checks []apiCheck `json:"checks"`
+}
+
+type apiWebsite struct {
+	TestId          string   `json:"testId"`
+	Name            string   `json:"name"`
+	Url             string   `json:"url"`
+	Method          string   `json:"method"`
+	Severity        string   `json:"severity"`
+	IntervalSeconds float64  `json:"intervalSeconds"`
+	DependsOn       []string `json:"dependsOn,omitempty"`
+}
+
+type apiOpenAlert struct {
+	TestId         string     `json:"testId"`
+	Subject        string     `json:"subject"`
+	Since          time.Time  `json:"since"`
+	EscalationTier int        `json:"escalationTier"`
+	Acknowledged   bool       `json:"acknowledged"`
+	AckExpiresAt   *time.Time `json:"ackExpiresAt,omitempty"`
+	Channels       []string   `json:"channels"`
+}
+
+func registerApiHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/api/servers", requireToken(apiGet(handleApiServers)))
+	mux.HandleFunc("/api/groups", requireToken(apiGet(handleApiGroups)))
+	mux.HandleFunc("/api/checks", requireToken(apiGet(handleApiChecks)))
+	mux.HandleFunc("/api/websites", requireToken(apiGet(handleApiWebsites)))
+	mux.HandleFunc("/api/results/servers", requireToken(apiGet(handleApiServerResults)))
+	mux.HandleFunc("/api/results/websites", requireToken(apiGet(handleApiWebsiteResults)))
+	mux.HandleFunc("/api/alerts", requireToken(apiGet(handleApiAlerts)))
+}
+
+// apiGet restricts a read-only endpoint to GET requests.
+func apiGet(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			writer.Header().Set("Allow", http.MethodGet)
+			writeJsonError(writer, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		handler(writer, request)
+	}
+}
+
+func newApiCheck(check Check, server *ServerConfig) apiCheck {
+	checkApi := apiCheck{
+		Name:            check.Name,
+		Group:           check.groupName,
+		Severity:        check.SeverityType,
+		IntervalSeconds: check.GetInterval(server).Seconds(),
+		TimeoutSeconds:  check.GetTimeout(server).Seconds(),
+		DependsOn:       check.DependsOn,
+	}
+	if server != nil {
+		checkApi.TestId = (&ServerCheck{Server: server, Check: &check}).GetTestId()
+		checkApi.Server = server.Name
+		if checkApi.Severity == "" {
+			checkApi.Severity = server.SeverityType
+		}
+	}
+
+	return checkApi
+}
+
+func handleApiServers(writer http.ResponseWriter, request *http.Request) {
+	configLock.RLock()
+
+	response := make([]apiServer, 0, len(servers))
+	for i := range servers {
+		server := &servers[i]
+		serverApi := apiServer{
+			Name:            server.Name,
+			Host:            server.Host,
+			Port:            server.Port,
+			Severity:        server.SeverityType,
+			IntervalSeconds: server.GetInterval().Seconds(),
+			Groups:          server.Groups,
+			Connected:       server.Session != nil && server.Session.IsConnected(),
+			Checks:          make([]apiCheck, 0),
+		}
+		for _, check := range server.GetChecks() {
+			serverApi.Checks = append(serverApi.Checks, newApiCheck(check, server))
+		}
+		response = append(response, serverApi)
+	}
+	configLock.RUnlock()
+
+	writeJson(writer, http.StatusOK, response)
+}
+
+func handleApiGroups(writer http.ResponseWriter, request *http.Request) {
+	configLock.RLock()
+
+	response := make([]apiGroup, 0, len(groups))
+	for _, group := range groups {
+		groupApi := apiGroup{
+			Name:   group.Name,
+			Checks: make([]apiCheck, 0, len(group.Checks)),
+		}
+		for _, check := range group.Checks {
+			check.groupName = group.Name
+			groupApi.Checks = append(groupApi.Checks, newApiCheck(check, nil))
+		}
+		response = append(response, groupApi)
+	}
+	configLock.RUnlock()
+
+	writeJson(writer, http.StatusOK, response)
+}
+
+// handleApiChecks lists every check as it runs on each server, optionally only those for the `server` given.
+func handleApiChecks(writer http.ResponseWriter, request *http.Request) {
+	serverName := request.URL.Query().Get("server")
+	configLock.RLock()
+
+	response := make([]apiCheck, 0)
+	for i := range servers {
+		server := &servers[i]
+		if serverName != "" && server.Name != serverName {
+			continue
+		}
+		for _, check := range server.GetChecks() {
+			response = append(response, newApiCheck(check, server))
+		}
+	}
+	configLock.RUnlock()
+
+	writeJson(writer, http.StatusOK, response)
+}
+
+func handleApiWebsites(writer http.ResponseWriter, request *http.Request) {
+	configLock.RLock()
+
+	response := make([]apiWebsite, 0, len(websites))
+	for i := range websites {
+		website := &websites[i]
+		method := website.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		response = append(response, apiWebsite{
+			TestId:          (&WebsiteCheck{Website: website}).GetTestId(),
+			Name:            website.Name,
+			Url:             website.Url,
+			Method:          method,
+			Severity:        website.SeverityType,
+			IntervalSeconds: website.GetInterval().Seconds(),
+			DependsOn:       website.DependsOn,
+		})
+	}
+	configLock.RUnlock()
+
+	writeJson(writer, http.StatusOK, response)
+}
+
+// parseHistoryFilter reads `testId`, `from` and `to` (RFC 3339), `passed` and `size` from the query string.
+// The range defaults to the last day and the size to 100 documents.
+func parseHistoryFilter(request *http.Request) (HistoryFilter, error) {
+	query := request.URL.Query()
+	filter := HistoryFilter{
+		Id:   query.Get("testId"),
+		To:   time.Now(),
+		Size: defaultApiResultSize,
+	}
+	var err error
+	if value := query.Get("to"); value != "" {
+		filter.To, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New(fmt.Sprintf("Invalid `to` time: %v", err))
+		}
+	}
+	filter.From = filter.To.Add(-defaultApiHistory)
+	if value := query.Get("from"); value != "" {
+		filter.From, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New(fmt.Sprintf("Invalid `from` time: %v", err))
+		}
+	}
+	if filter.From.After(filter.To) {
+		return filter, errors.New("`from` must be before `to`")
+	}
+	if value := query.Get("passed"); value != "" {
+		passed, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New(fmt.Sprintf("Invalid `passed` value: %v", value))
+		}
+		filter.Passed = &passed
+	}
+	if value := query.Get("size"); value != "" {
+		filter.Size, err = strconv.Atoi(value)
+		if err != nil || filter.Size < 1 || filter.Size > maxApiResultSize {
+			return filter, errors.New(fmt.Sprintf("`size` must be between 1 and %d", maxApiResultSize))
+		}
+	}
+
+	return filter, nil
+}
+
+func handleApiServerResults(writer http.ResponseWriter, request *http.Request) {
+	filter, err := parseHistoryFilter(request)
+	if err != nil {
+		writeJsonError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	results, err := SearchServerChecks(filter)
+	if err != nil {
+		Error(err)
+		writeJsonError(writer, http.StatusInternalServerError, "Could not search server results")
+		return
+	}
+
+	writeJson(writer, http.StatusOK, results)
+}
+
+func handleApiWebsiteResults(writer http.ResponseWriter, request *http.Request) {
+	filter, err := parseHistoryFilter(request)
+	if err != nil {
+		writeJsonError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	results, err := SearchWebsiteChecks(filter)
+	if err != nil {
+		Error(err)
+		writeJsonError(writer, http.StatusInternalServerError, "Could not search website results")
+		return
+	}
+
+	writeJson(writer, http.StatusOK, results)
+}
+
+// handleApiAlerts lists the alerts currently open, or with `history=true` the alert documents matching the
+// history filter, optionally narrowed to one `state`.
+func handleApiAlerts(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	if history, _ := strconv.ParseBool(query.Get("history")); !history {
+		testId := query.Get("testId")
+		response := make([]apiOpenAlert, 0)
+		for alertTestId, state := range getAlertStates() {
+			if testId != "" && alertTestId != testId {
+				continue
+			}
+			response = append(response, apiOpenAlert{
+				TestId:         alertTestId,
+				Subject:        state.Subject,
+				Since:          state.Since,
+				EscalationTier: state.EscalationLevel + 1,
+				Acknowledged:   state.IsAcknowledged(),
+				AckExpiresAt:   state.AckExpiresAt,
+				Channels:       state.Channels,
+			})
+		}
+		sort.Slice(response, func(i, j int) bool {
+			return response[i].Since.Before(response[j].Since)
+		})
+		writeJson(writer, http.StatusOK, response)
+		return
+	}
+
+	filter, err := parseHistoryFilter(request)
+	if err != nil {
+		writeJsonError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Passed = nil
+	filter.State = query.Get("state")
+	alerts, err := SearchAlerts(filter)
+	if err != nil {
+		Error(err)
+		writeJsonError(writer, http.StatusInternalServerError, "Could not search alerts")
+		return
+	}
+
+	writeJson(writer, http.StatusOK, alerts)
+}
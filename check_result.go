@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultSuppressionWindow is how often a suppressed alert is recorded for a severity without a resend window.
+const defaultSuppressionWindow = time.Hour
+
+// CheckResult is the common view of a stored check outcome (server or website)
+// used to decide whether an alert should be raised for it.
+type CheckResult interface {
+	GetTestId() string
+	GetIdentity() CheckIdentity
+	HasPassed() bool
+	GetTimestamp() time.Time
+	IsAlwaysSevere() bool
+	GetSeverity() *SeverityConfig
+	GetSeverityName() string
+	GetHistorySince(timeFrom time.Time) (*[]CheckResult, error)
+	GetAlertOverrides() []map[string]bool
+	GetDetails() []AlertDetail
+	GetDependsOn() []string
+}
+
+// CanSendAlert resolves whether an alert channel is used for a check result. The result's own overrides are
+// consulted first, most specific first, then its severity's alerts and finally the channel default.
+func CanSendAlert(checkResult CheckResult, alert string, defaultValue bool) bool {
+	overrides := checkResult.GetAlertOverrides()
+	if severityConfig := checkResult.GetSeverity(); severityConfig != nil {
+		overrides = append(overrides, severityConfig.Alerts)
+	}
+	for _, alerts := range overrides {
+		if val, ok := alerts[alert]; ok {
+			return val
+		}
+	}
+
+	return defaultValue
+}
+
+func IsSevere(checkResult CheckResult) bool {
+	if !checkResult.HasPassed() && checkResult.IsAlwaysSevere() {
+		return true
+	}
+
+	severityConfig := checkResult.GetSeverity()
+
+	if severityConfig == nil {
+		Error(fmt.Sprintf("No severity set for `%v` - not sending alert", checkResult.GetTestId()))
+
+		return false
+	}
+
+	failurePercentage, hasHistory, err := GetFailurePercentage(checkResult, severityConfig)
+	if err != nil {
+		Error("Failed to get results matching `", checkResult.GetTestId(), "`: ", err)
+
+		return true
+	}
+
+	return hasHistory && failurePercentage > float32(severityConfig.FailedAttemptsPercentage)
+}
+
+// GetFailurePercentage returns the percentage of failed results within the severity's check window, and
+// whether results older than the window exist, i.e. whether the window has been fully observed.
+func GetFailurePercentage(checkResult CheckResult, severityConfig *SeverityConfig) (float32, bool, error) {
+	timeFrom := time.Now().Add(-severityConfig.CheckMinutes * time.Minute)
+	results, err := checkResult.GetHistorySince(timeFrom)
+	if err != nil {
+		return 0, false, err
+	}
+
+	hasOlder := false
+	var failureCount float32 = 0
+	var totalCount float32 = 0
+	for _, result := range *results {
+		if result.GetTimestamp().Before(timeFrom) {
+			hasOlder = true
+			continue
+		}
+		totalCount++
+		if !result.HasPassed() {
+			failureCount++
+		}
+	}
+	if totalCount == 0 {
+		return 0, hasOlder, nil
+	}
+
+	return (failureCount / totalCount) * 100, hasOlder, nil
+}
+
+// HasRecordedSuppression reports whether the latest alert for the result within its resend window was already
+// suppressed for the same reason, so a suppression is recorded once per window rather than on every run.
+func HasRecordedSuppression(checkResult CheckResult, suppressedBy string) bool {
+	window := defaultSuppressionWindow
+	if severityConfig := checkResult.GetSeverity(); severityConfig != nil && severityConfig.AlertResendMinutes > 0 {
+		window = severityConfig.AlertResendMinutes * time.Minute
+	}
+	results, err := GetAlertsSince(checkResult.GetTestId(), time.Now().Add(-window))
+	if err != nil {
+		Error("Failed to get alerts matching `", checkResult.GetTestId(), "`: ", err)
+
+		return false
+	}
+
+	var latest *Alert
+	for i, alert := range *results {
+		if alert.State != alertStateAcknowledged {
+			latest = &(*results)[i]
+		}
+	}
+
+	return latest != nil && latest.Suppressed && latest.SuppressedBy == suppressedBy
+}
+
+func CanResendAlert(checkResult CheckResult) bool {
+	if GetActiveAcknowledgement(checkResult.GetTestId()) != nil {
+		return false
+	}
+
+	severityConfig := checkResult.GetSeverity()
+	if severityConfig == nil {
+		return true
+	}
+
+	timeFrom := time.Now().Add(-severityConfig.AlertResendMinutes * time.Minute)
+	results, err := GetAlertsSince(checkResult.GetTestId(), timeFrom)
+	if err != nil {
+		Error("Failed to get alerts matching `", checkResult.GetTestId(), "`: ", err)
+
+		return true
+	}
+
+	// Alerts are sorted oldest first, so a recovery resets suppression for any alerts sent before it. An
+	// alert no channel managed to deliver does not hold back the next attempt.
+	canResend := true
+	for _, alert := range *results {
+		if alert.Suppressed || alert.State == alertStateAcknowledged {
+			continue
+		}
+		if len(alert.Deliveries) > 0 && len(deliveredChannels(alert.Deliveries)) == 0 {
+			continue
+		}
+		canResend = alert.State == alertStateResolved
+	}
+
+	return canResend
+}
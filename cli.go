@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runAckCommand acknowledges an alert through the HTTP API of a running monitor:
+//
+//	server-monitor ack [-by name] [-comment text] [-expires minutes] <testId>
+func runAckCommand(args []string) error {
+	flags := flag.NewFlagSet("ack", flag.ExitOnError)
+	by := flags.String("by", os.Getenv("USER"), "who is acknowledging the alert")
+	comment := flags.String("comment", "", "comment to store with the acknowledgement")
+	expires := flags.Int("expires", 0, "minutes until the acknowledgement expires, 0 to keep it until the check recovers")
+	address := flags.String("address", "", "address of the running monitor, defaults to http.address in config.json")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: server-monitor ack [flags] <testId>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("Exactly one test id must be given")
+	}
+
+	err := loadMonitorConfig("global")
+	if err != nil {
+		return err
+	}
+	if *address == "" {
+		*address = config.Http.GetAddress()
+	}
+
+	request := httpClient.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(acknowledgeRequest{
+			TestId:         flags.Arg(0),
+			By:             *by,
+			Comment:        *comment,
+			ExpiresMinutes: time.Duration(*expires),
+		})
+	if config.Http.Token != "" {
+		request.SetHeader("Authorization", "Bearer "+config.Http.Token)
+	}
+	response, err := request.Post(fmt.Sprintf("http://%v/api/alerts/acknowledge", *address))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not reach monitor at %v: %v", *address, err))
+	}
+	if response.IsError() {
+		return errors.New(fmt.Sprintf("Acknowledgement failed with status %v: %v", response.StatusCode(), response.String()))
+	}
+	InfoBold("Acknowledged `", flags.Arg(0), "`")
+
+	return nil
+}
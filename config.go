@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"gopkg.in/resty.v1"
@@ -14,10 +16,25 @@ import (
 type Check struct {
 	Name             string
 	SeverityType     string `json:"severity"`
+	Interval         time.Duration
 	Command          string
+	TimeoutSeconds   time.Duration
 	ResponseContains string
+	ExitCode         *int
+	ExitCodeIn       []int
 	Regex            *Regex
-	Alert            string
+	Remediation      *Remediation
+	Alerts           map[string]bool
+	DependsOn        []string
+	groupName        string
+}
+
+type Remediation struct {
+	Command             string
+	TimeoutSeconds      time.Duration
+	MaxAttempts         int
+	WindowMinutes       time.Duration
+	RecheckDelaySeconds time.Duration
 }
 
 type Regex struct {
@@ -29,14 +46,59 @@ type Regex struct {
 	Contains    string
 }
 
+type RetryConfig struct {
+	Attempts        int
+	DelaySeconds    time.Duration
+	MaxDelaySeconds time.Duration
+}
+
+// AlertChannelConfig holds the settings shared by every alert channel under `alerts`.
+type AlertChannelConfig struct {
+	Enabled  bool
+	Default  bool
+	Retry    RetryConfig
+	Fallback string
+}
+
 type SimplePushAlert struct {
-	Code    string
-	Enabled bool
-	Default bool
+	AlertChannelConfig
+	Code string
+}
+
+type EmailAlert struct {
+	AlertChannelConfig
+	Host     string
+	Port     int
+	Security string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Cc       []string
+	Bcc      []string
+}
+
+type WebhookAlert struct {
+	AlertChannelConfig
+	Url     string
+	Method  string
+	Headers map[string]string
+	Body    string
+}
+
+// AlertGroupingConfig batches a server's alerts raised within WindowSeconds into a single digest.
+type AlertGroupingConfig struct {
+	Enabled       bool
+	WindowSeconds time.Duration
+	ByGroup       bool
 }
 
 type AlertConfig struct {
-	SimplePush SimplePushAlert
+	SimplePush     SimplePushAlert
+	Email          EmailAlert
+	Webhooks       map[string]WebhookAlert
+	Grouping       AlertGroupingConfig
+	DependencyMode string
 }
 
 type ElasticConfig struct {
@@ -46,36 +108,67 @@ type ElasticConfig struct {
 	Password string
 }
 
+type SshConfig struct {
+	KnownHostsFile        string
+	TrustOnFirstUse       bool
+	ConnectTimeoutSeconds time.Duration
+	KeepAliveSeconds      time.Duration
+	ReconnectMinSeconds   time.Duration
+	ReconnectMaxSeconds   time.Duration
+}
+
+type HttpConfig struct {
+	Enabled   bool
+	Address   string
+	Token     string
+	Dashboard bool
+}
+
 type MonitorConfig struct {
-	CheckInterval time.Duration
-	Alerts        AlertConfig
-	Elastic       ElasticConfig
+	CheckInterval          time.Duration
+	CommandTimeoutSeconds  time.Duration
+	MaxConcurrentChecks    int
+	ShutdownTimeoutSeconds time.Duration
+	Alerts                 AlertConfig
+	Elastic                ElasticConfig
+	Ssh                    SshConfig
+	Http                   HttpConfig
 }
 
 type SeverityConfig struct {
 	CheckMinutes             time.Duration
 	FailedAttemptsPercentage int16
+	InterventionPercentage   int16
 	AlertResendMinutes       time.Duration
 	Alerts                   map[string]bool
+	Escalation               []EscalationTier
 }
 
 type ServerConfig struct {
-	Name         string
-	Host         string
-	Port         int16
-	Username     string
-	Password     string
-	SeverityType string `json:"severity"`
-	Groups       []string
-	Checks       []Check
-	Session      *sshSession
-	Alerts       map[string]bool
-	inProgress   bool
+	Name                  string
+	Host                  string
+	Port                  int16
+	Interval              time.Duration
+	Username              string
+	Password              string
+	PrivateKeyPath        string
+	PrivateKeyPassphrase  string
+	AgentSocket           string
+	UseAgent              bool
+	AuthMethods           []string
+	HostKeyFingerprints   []string
+	CommandTimeoutSeconds time.Duration
+	SeverityType          string `json:"severity"`
+	Groups                []string
+	Checks                []Check
+	Session               *sshSession
+	Alerts                map[string]bool
 }
 
 type WebsiteConfig struct {
 	Name              string
 	SeverityType      string `json:"severity"`
+	Interval          time.Duration
 	Url               string
 	Method            string
 	StatusCode        int
@@ -83,12 +176,14 @@ type WebsiteConfig struct {
 	ResponseHeaders   map[string]string
 	RequestHeaders    map[string]string
 	RequestBody       string
-	inProgress        bool
+	Alerts            map[string]bool
+	DependsOn         []string
 }
 
 type GroupConfig struct {
-	Name   string
-	Checks []Check
+	Name     string
+	Interval time.Duration
+	Checks   []Check
 }
 
 type configFile struct {
@@ -98,8 +193,15 @@ type configFile struct {
 	loadMethod     func(string) error
 	preLoadMethod  func()
 	postLoadMethod func()
+	// connectsServers reconnects to every server once all config changes have loaded
+	connectsServers bool
 }
 
+const defaultCheckInterval = 60 * time.Second
+const defaultCommandTimeout = 60 * time.Second
+const defaultShutdownTimeout = 30 * time.Second
+const defaultHttpAddress = "127.0.0.1:8080"
+
 var configFileOrder []string
 var configFiles map[string]configFile
 var config MonitorConfig
@@ -107,9 +209,14 @@ var severity map[string]SeverityConfig
 var servers []ServerConfig
 var groups []GroupConfig
 var websites []WebsiteConfig
+var maintenance MaintenanceConfig
 
 var httpClient = resty.New()
 
+// configLock guards the loaded config, servers, groups, websites, severities and maintenance for readers
+// outside the main loop, such as HTTP handlers. The main loop is the only writer so reads there go without it.
+var configLock sync.RWMutex
+
 func init() {
 	configFileOrder = []string{
 		"global",
@@ -117,11 +224,13 @@ func init() {
 		"servers",
 		"groups",
 		"websites",
+		"maintenance",
 	}
 	configFiles = map[string]configFile{
 		"global": {
-			path:       "config.json",
-			loadMethod: loadMonitorConfig,
+			path:           "config.json",
+			loadMethod:     loadMonitorConfig,
+			postLoadMethod: applyMonitorConfig,
 		},
 		"severity": {
 			path:        "severity.json",
@@ -129,25 +238,30 @@ func init() {
 			loadDefault: true,
 		},
 		"servers": {
-			path:           "servers.json",
-			loadMethod:     loadServerConfig,
-			preLoadMethod:  disconnectAllServers,
-			postLoadMethod: connectToServers,
-			loadDefault:    true,
+			path:            "servers.json",
+			loadMethod:      loadServerConfig,
+			preLoadMethod:   disconnectAllServers,
+			connectsServers: true,
+			loadDefault:     true,
 		},
 		"groups": {
-			path:           "groups.json",
-			loadMethod:     loadGroupsConfig,
-			preLoadMethod:  disconnectAllServers,
-			postLoadMethod: connectToServers,
-			loadDefault:    true,
+			path:            "groups.json",
+			loadMethod:      loadGroupsConfig,
+			preLoadMethod:   disconnectAllServers,
+			connectsServers: true,
+			loadDefault:     true,
 		},
 		"websites": {
-			path:           "websites.json",
-			loadMethod:     loadWebsitesConfig,
-			preLoadMethod:  disconnectAllServers,
-			postLoadMethod: connectToServers,
-			loadDefault:    true,
+			path:            "websites.json",
+			loadMethod:      loadWebsitesConfig,
+			preLoadMethod:   disconnectAllServers,
+			connectsServers: true,
+			loadDefault:     true,
+		},
+		"maintenance": {
+			path:        "maintenance.json",
+			loadMethod:  loadMaintenanceConfig,
+			loadDefault: true,
 		},
 	}
 
@@ -169,18 +283,64 @@ func loadJson(configName string) []byte {
 }
 
 func loadMonitorConfig(configName string) error {
-	return json.Unmarshal(loadJson(configName), &config)
+	err := json.Unmarshal(loadJson(configName), &config)
+	if err != nil {
+		return err
+	}
+
+	switch config.Alerts.DependencyMode {
+	case "", dependencyModeSuppress, dependencyModeAnnotate:
+	default:
+		return errors.New(fmt.Sprintf("Unknown dependency mode `%v`", config.Alerts.DependencyMode))
+	}
+	for name, webhook := range config.Alerts.Webhooks {
+		if webhook.Url == "" {
+			Warn("URL not specified for webhook `", name, "`")
+		}
+		if _, err := parseWebhookBody(name, webhook); err != nil {
+			return errors.New(fmt.Sprintf("Invalid body template for webhook `%v`: %v", name, err))
+		}
+	}
+
+	return nil
+}
+
+// applyMonitorConfig runs after the global config loads. The database is connected here, before the servers
+// config connects to servers, as connection changes are saved as soon as they happen.
+func applyMonitorConfig() {
+	if database == nil {
+		InitiateDatabase()
+	}
+	registerNotifiers()
 }
 
 func loadSeverityConfig(configName string) error {
-	return json.Unmarshal(loadJson(configName), &severity)
+	loadedSeverity := make(map[string]SeverityConfig)
+	err := json.Unmarshal(loadJson(configName), &loadedSeverity)
+	if err != nil {
+		return err
+	}
+	for name, severityConfig := range loadedSeverity {
+		sort.SliceStable(severityConfig.Escalation, func(i, j int) bool {
+			return severityConfig.Escalation[i].AfterMinutes < severityConfig.Escalation[j].AfterMinutes
+		})
+		if len(severityConfig.Escalation) > 0 && severityConfig.Escalation[0].AfterMinutes > 0 {
+			Warn("First escalation tier for severity `", name, "` starts after ", int64(severityConfig.Escalation[0].AfterMinutes),
+				" minutes - no channels are notified before then")
+		}
+	}
+	severity = loadedSeverity
+
+	return nil
 }
 
 func loadServerConfig(configName string) error {
-	err := json.Unmarshal(loadJson(configName), &servers)
+	loadedServers := make([]ServerConfig, 0)
+	err := json.Unmarshal(loadJson(configName), &loadedServers)
 	if err != nil {
 		return err
 	}
+	servers = loadedServers
 
 	for _, server := range servers {
 		if server.SeverityType == "" {
@@ -194,15 +354,23 @@ func loadServerConfig(configName string) error {
 }
 
 func loadGroupsConfig(configName string) error {
-	return json.Unmarshal(loadJson(configName), &groups)
+	loadedGroups := make([]GroupConfig, 0)
+	err := json.Unmarshal(loadJson(configName), &loadedGroups)
+	if err != nil {
+		return err
+	}
+	groups = loadedGroups
+
+	return nil
 }
 
 func loadWebsitesConfig(configName string) error {
-	// websites = make([]WebsiteConfig, 0)
-	err := json.Unmarshal(loadJson(configName), &websites)
+	loadedWebsites := make([]WebsiteConfig, 0)
+	err := json.Unmarshal(loadJson(configName), &loadedWebsites)
 	if err != nil {
 		return err
 	}
+	websites = loadedWebsites
 
 	for _, website := range websites {
 		if website.SeverityType == "" {
@@ -215,6 +383,24 @@ func loadWebsitesConfig(configName string) error {
 	return nil
 }
 
+func loadMaintenanceConfig(configName string) error {
+	var loadedMaintenance MaintenanceConfig
+	err := json.Unmarshal(loadJson(configName), &loadedMaintenance)
+	if err != nil {
+		return err
+	}
+
+	for i := range loadedMaintenance.Windows {
+		err = loadedMaintenance.Windows[i].validate()
+		if err != nil {
+			return err
+		}
+	}
+	maintenance = loadedMaintenance
+
+	return nil
+}
+
 func updateConfigModifiedTime(name string) {
 	thisConfig := configFiles[name]
 	modifiedTime, err := thisConfig.getConfigModifiedTime()
@@ -228,6 +414,11 @@ func updateConfigModifiedTime(name string) {
 }
 
 func CheckConfigChanges() {
+	holdHostKeyAlerts()
+	defer releaseHostKeyAlerts()
+	configLock.Lock()
+	globalChanged := false
+	serversChanged := false
 	// for configName, config := range configFiles {
 	for _, configName := range configFileOrder {
 		config, ok := configFiles[configName]
@@ -249,8 +440,23 @@ func CheckConfigChanges() {
 			if config.postLoadMethod != nil {
 				config.postLoadMethod()
 			}
+			globalChanged = globalChanged || configName == "global"
+			serversChanged = serversChanged || config.connectsServers
 		}
 	}
+	if serversChanged {
+		newServerSessions()
+	}
+	configLock.Unlock()
+
+	// Connecting can wait for the connect timeout on each server, so it is left out of the lock
+	if serversChanged {
+		connectToServers()
+	}
+	// Stopping the HTTP server waits for running handlers, which may be waiting for the config lock
+	if globalChanged {
+		restartHttpServer()
+	}
 }
 
 func HasConfigChanges() bool {
@@ -298,14 +504,6 @@ func (config *configFile) getFilePath() (string, error) {
 	return path, nil
 }
 
-func (server *ServerConfig) CanSendAlert(alert string, defaultValue bool) bool {
-	if val, ok := server.Alerts[alert]; ok {
-		return val
-	}
-
-	return defaultValue
-}
-
 func getSeverity(severityType string) *SeverityConfig {
 	if severityType == "" {
 		return nil
@@ -322,6 +520,123 @@ func (check *Check) Severity() *SeverityConfig {
 	return getSeverity(check.SeverityType)
 }
 
+// GetTimeout resolves how long the check's command may run on the server, falling back to the server
+// and then the global default.
+func (check *Check) GetTimeout(server *ServerConfig) time.Duration {
+	if check.TimeoutSeconds > 0 {
+		return check.TimeoutSeconds * time.Second
+	}
+	if server != nil && server.CommandTimeoutSeconds > 0 {
+		return server.CommandTimeoutSeconds * time.Second
+	}
+	if config.CommandTimeoutSeconds > 0 {
+		return config.CommandTimeoutSeconds * time.Second
+	}
+
+	return defaultCommandTimeout
+}
+
+func getCheckInterval() time.Duration {
+	if config.CheckInterval > 0 {
+		return config.CheckInterval * time.Second
+	}
+
+	return defaultCheckInterval
+}
+
+func getGroup(name string) *GroupConfig {
+	for i := 0; i < len(groups); i++ {
+		if groups[i].Name == name {
+			return &groups[i]
+		}
+	}
+
+	return nil
+}
+
+// GetChecks returns the checks from the server's groups followed by its own checks, with a server check
+// replacing any group check of the same name.
+func (server *ServerConfig) GetChecks() []Check {
+	checks := make([]Check, 0)
+	checkIndexes := make(map[string]int, 0)
+	addCheck := func(check Check) {
+		if index, ok := checkIndexes[check.Name]; ok {
+			checks[index] = check
+			return
+		}
+		checkIndexes[check.Name] = len(checks)
+		checks = append(checks, check)
+	}
+	for _, serverGroup := range server.Groups {
+		group := getGroup(serverGroup)
+		if group == nil {
+			continue
+		}
+		for _, check := range group.Checks {
+			check.groupName = group.Name
+			addCheck(check)
+		}
+	}
+	for _, check := range server.Checks {
+		addCheck(check)
+	}
+
+	return checks
+}
+
+func (server *ServerConfig) GetInterval() time.Duration {
+	if server.Interval > 0 {
+		return server.Interval * time.Second
+	}
+
+	return getCheckInterval()
+}
+
+// GetInterval resolves how often the check runs on the server: the check's own interval, then its group's,
+// then the server's and finally the global check interval.
+func (check *Check) GetInterval(server *ServerConfig) time.Duration {
+	if check.Interval > 0 {
+		return check.Interval * time.Second
+	}
+	if group := getGroup(check.groupName); group != nil && group.Interval > 0 {
+		return group.Interval * time.Second
+	}
+	if server != nil {
+		return server.GetInterval()
+	}
+
+	return getCheckInterval()
+}
+
+func (website *WebsiteConfig) GetInterval() time.Duration {
+	if website.Interval > 0 {
+		return website.Interval * time.Second
+	}
+
+	return getCheckInterval()
+}
+
+func (check *Check) HasExitCodeCondition() bool {
+	return check.ExitCode != nil || len(check.ExitCodeIn) > 0
+}
+
+func (check *Check) AcceptsExitCode(exitCode int) bool {
+	if check.ExitCode != nil && *check.ExitCode != exitCode {
+		return false
+	}
+	if len(check.ExitCodeIn) > 0 {
+		for _, allowed := range check.ExitCodeIn {
+			if allowed == exitCode {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
 func (server *ServerConfig) Severity() *SeverityConfig {
 	return getSeverity(server.SeverityType)
 }
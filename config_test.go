@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerGetChecks(t *testing.T) {
+	groups = []GroupConfig{
+		{Name: "apache", Checks: []Check{{Name: "apache online"}, {Name: "disk space", Command: "df"}}},
+		{Name: "mysql", Checks: []Check{{Name: "mysql running"}}},
+	}
+	defer func() {
+		groups = nil
+	}()
+	server := &ServerConfig{
+		Name:   "Web",
+		Groups: []string{"apache", "missing", "mysql"},
+		Checks: []Check{{Name: "disk space", Command: "df -h"}, {Name: "load"}},
+	}
+
+	checks := server.GetChecks()
+	names := make([]string, 0, len(checks))
+	for _, check := range checks {
+		names = append(names, check.Name)
+	}
+	expected := []string{"apache online", "disk space", "mysql running", "load"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected checks %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected checks %v, got %v", expected, names)
+		}
+	}
+	if checks[1].Command != "df -h" || checks[1].groupName != "" {
+		t.Errorf("server check should replace the group check of the same name, got %+v", checks[1])
+	}
+	if checks[0].groupName != "apache" || checks[2].groupName != "mysql" {
+		t.Errorf("group checks should record their group, got `%v` and `%v`", checks[0].groupName, checks[2].groupName)
+	}
+}
+
+func TestCheckGetInterval(t *testing.T) {
+	groups = []GroupConfig{
+		{Name: "slow", Interval: 300},
+		{Name: "default"},
+	}
+	defer func() {
+		groups = nil
+		config.CheckInterval = 0
+	}()
+	config.CheckInterval = 0
+
+	tests := []struct {
+		name     string
+		check    Check
+		server   *ServerConfig
+		expected time.Duration
+	}{
+		{"check", Check{Interval: 10, groupName: "slow"}, &ServerConfig{Interval: 20}, 10 * time.Second},
+		{"group", Check{groupName: "slow"}, &ServerConfig{Interval: 20}, 300 * time.Second},
+		{"server", Check{groupName: "default"}, &ServerConfig{Interval: 20}, 20 * time.Second},
+		{"default", Check{}, &ServerConfig{}, defaultCheckInterval},
+		{"no server", Check{}, nil, defaultCheckInterval},
+	}
+	for _, test := range tests {
+		if interval := test.check.GetInterval(test.server); interval != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, interval)
+		}
+	}
+
+	config.CheckInterval = 30
+	if interval := (&Check{}).GetInterval(&ServerConfig{}); interval != 30*time.Second {
+		t.Errorf("global: expected 30s, got %v", interval)
+	}
+}
+
+func TestCheckGetTimeout(t *testing.T) {
+	defer func() {
+		config.CommandTimeoutSeconds = 0
+	}()
+	config.CommandTimeoutSeconds = 0
+
+	if timeout := (&Check{TimeoutSeconds: 5}).GetTimeout(&ServerConfig{CommandTimeoutSeconds: 10}); timeout != 5*time.Second {
+		t.Errorf("check: expected 5s, got %v", timeout)
+	}
+	if timeout := (&Check{}).GetTimeout(&ServerConfig{CommandTimeoutSeconds: 10}); timeout != 10*time.Second {
+		t.Errorf("server: expected 10s, got %v", timeout)
+	}
+	if timeout := (&Check{}).GetTimeout(nil); timeout != defaultCommandTimeout {
+		t.Errorf("default: expected %v, got %v", defaultCommandTimeout, timeout)
+	}
+	config.CommandTimeoutSeconds = 20
+	if timeout := (&Check{}).GetTimeout(&ServerConfig{}); timeout != 20*time.Second {
+		t.Errorf("global: expected 20s, got %v", timeout)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a standard five field cron expression: minute, hour, day of month, month and day of week.
+type cronSchedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	anyDay   bool
+	anyWeek  bool
+}
+
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New(fmt.Sprintf("Cron expression `%v` must have 5 fields", expression))
+	}
+
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	values := make([]map[int]bool, 5)
+	for i, field := range fields {
+		parsed, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Cron expression `%v`: %v", expression, err))
+		}
+		values[i] = parsed
+	}
+	// Sunday may be written as 0 or 7
+	if values[4][7] {
+		values[4][0] = true
+	}
+
+	return &cronSchedule{
+		minutes:  values[0],
+		hours:    values[1],
+		days:     values[2],
+		months:   values[3],
+		weekdays: values[4],
+		anyDay:   fields[2] == "*",
+		anyWeek:  fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, lowest int, highest int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index != -1 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step <= 0 {
+				return nil, errors.New(fmt.Sprintf("invalid step in `%v`", part))
+			}
+			part = part[:index]
+		}
+
+		start, end := lowest, highest
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid value `%v`", part))
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("invalid range `%v`", part))
+				}
+			} else if step > 1 {
+				end = highest
+			}
+		}
+		if start < lowest || end > highest || start > end {
+			return nil, errors.New(fmt.Sprintf("`%v` is out of range %d-%d", part, lowest, highest))
+		}
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+func (schedule *cronSchedule) Matches(moment time.Time) bool {
+	if !schedule.minutes[moment.Minute()] || !schedule.hours[moment.Hour()] || !schedule.months[int(moment.Month())] {
+		return false
+	}
+
+	dayMatches := schedule.days[moment.Day()]
+	weekdayMatches := schedule.weekdays[int(moment.Weekday())]
+	switch {
+	case schedule.anyDay && schedule.anyWeek:
+		return true
+	case schedule.anyDay:
+		return weekdayMatches
+	case schedule.anyWeek:
+		return dayMatches
+	default:
+		return dayMatches || weekdayMatches
+	}
+}
+
+// StartedWithin reports whether the schedule fired at any minute in the duration leading up to the moment.
+func (schedule *cronSchedule) StartedWithin(moment time.Time, duration time.Duration) bool {
+	earliest := moment.Add(-duration)
+	for start := moment.Truncate(time.Minute); !start.Before(earliest); start = start.Add(-time.Minute) {
+		if schedule.Matches(start) {
+			return true
+		}
+	}
+
+	return false
+}
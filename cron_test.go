@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	expressions := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	}
+	for _, expression := range expressions {
+		if _, err := parseCron(expression); err == nil {
+			t.Errorf("expected `%v` to be rejected", expression)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2026-01-04 is a Sunday
+	sunday := time.Date(2026, 1, 4, 2, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expression string
+		moment     time.Time
+		expected   bool
+	}{
+		{"* * * * *", sunday, true},
+		{"30 2 * * *", sunday, true},
+		{"31 2 * * *", sunday, false},
+		{"*/15 * * * *", sunday, true},
+		{"*/20 * * * *", sunday, false},
+		{"0-29 * * * *", sunday, false},
+		{"10,30,50 1-3 * * *", sunday, true},
+		{"30 2 * * 0", sunday, true},
+		{"30 2 * * 7", sunday, true},
+		{"30 2 * * 1-5", sunday, false},
+		{"30 2 4 * *", sunday, true},
+		{"30 2 5 * *", sunday, false},
+		{"30 2 * 2 *", sunday, false},
+		// Day of month and day of week both restricted: either may match
+		{"30 2 5 * 0", sunday, true},
+		{"30 2 4 * 1", sunday, true},
+		{"30 2 5 * 1", sunday, false},
+	}
+	for _, test := range tests {
+		schedule, err := parseCron(test.expression)
+		if err != nil {
+			t.Fatalf("`%v` should parse: %v", test.expression, err)
+		}
+		if matches := schedule.Matches(test.moment); matches != test.expected {
+			t.Errorf("`%v` at %v: expected %v, got %v", test.expression, test.moment, test.expected, matches)
+		}
+	}
+}
+
+func TestCronStartedWithin(t *testing.T) {
+	schedule, err := parseCron("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		moment   time.Time
+		duration time.Duration
+		expected bool
+	}{
+		{start, 0, true},
+		{start.Add(30 * time.Second), time.Minute, true},
+		{start.Add(59 * time.Minute), time.Hour, true},
+		{start.Add(time.Hour), time.Hour, true},
+		{start.Add(time.Hour + time.Minute), time.Hour, false},
+		{start.Add(-time.Minute), time.Hour, false},
+	}
+	for _, test := range tests {
+		if started := schedule.StartedWithin(test.moment, test.duration); started != test.expected {
+			t.Errorf("at %v within %v: expected %v, got %v", test.moment, test.duration, test.expected, started)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type dashboardCheck struct {
+	TestId            string
+	Name              string
+	Status            string
+	LastRun           time.Time
+	Output            string
+	FailurePercentage string
+	Alert             *dashboardAlert
+}
+
+type dashboardServer struct {
+	Name      string
+	Host      string
+	Connected bool
+	Checks    []dashboardCheck
+}
+
+type dashboardAlert struct {
+	TestId         string
+	Subject        string
+	Since          time.Time
+	EscalationTier int
+	Acknowledged   bool
+	Channels       []string
+}
+
+type dashboardData struct {
+	Generated time.Time
+	Servers   []dashboardServer
+	Websites  []dashboardCheck
+	Alerts    []dashboardAlert
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"time": func(value time.Time) string {
+		if value.IsZero() {
+			return "-"
+		}
+
+		return value.Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>Server Monitor</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #ddd; vertical-align: top; }
+pre { margin: 0; white-space: pre-wrap; max-height: 8em; overflow: auto; }
+.passing { color: #2a7d2a; }
+.failing { color: #c0392b; font-weight: bold; }
+.unknown { color: #888; }
+</style>
+</head>
+<body>
+<h1>Server Monitor</h1>
+<p>Updated {{time .Generated}}</p>
+
+<h2>Active alerts</h2>
+{{if .Alerts}}
+<table>
+<tr><th>Test</th><th>Subject</th><th>Since</th><th>Tier</th><th>Acknowledged</th><th>Channels</th></tr>
+{{range .Alerts}}
+<tr><td>{{.TestId}}</td><td>{{.Subject}}</td><td>{{time .Since}}</td><td>{{if .EscalationTier}}{{.EscalationTier}}{{else}}-{{end}}</td><td>{{if .Acknowledged}}yes{{else}}no{{end}}</td><td>{{range $i, $c := .Channels}}{{if $i}}, {{end}}{{$c}}{{end}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>No active alerts.</p>
+{{end}}
+
+{{define "checks"}}
+<table>
+<tr><th>Check</th><th>Status</th><th>Last run</th><th>Failure rate</th><th>Output</th></tr>
+{{range .}}
+<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}{{if .Alert}} (alerting){{end}}</td><td>{{time .LastRun}}</td><td>{{.FailurePercentage}}</td><td><pre>{{.Output}}</pre></td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{range .Servers}}
+<h2>{{.Name}} <small>{{.Host}}</small> <span class="{{if .Connected}}passing">connected{{else}}failing">not connected{{end}}</span></h2>
+{{template "checks" .Checks}}
+{{end}}
+
+{{if .Websites}}
+<h2>Websites</h2>
+{{template "checks" .Websites}}
+{{end}}
+</body>
+</html>
+`))
+
+// getAlertStates returns a copy of every active alert, keyed by test id.
+func getAlertStates() map[string]alertState {
+	alertStatesLock.Lock()
+	defer alertStatesLock.Unlock()
+
+	states := make(map[string]alertState, len(alertStates))
+	for testId, state := range alertStates {
+		states[testId] = *state
+	}
+
+	return states
+}
+
+// dashboardSource is a check copied out of the config, with its interval and severity resolved, so its
+// history can be looked up without holding the config lock.
+type dashboardSource struct {
+	checkResult    CheckResult
+	name           string
+	severityConfig *SeverityConfig
+}
+
+// dashboardSnapshot is what the dashboard needs from the config, taken while holding the config lock.
+type dashboardSnapshot struct {
+	servers      []dashboardServer
+	serverChecks [][]dashboardSource
+	websites     []dashboardSource
+}
+
+func handleDashboard(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path != "/" {
+		http.NotFound(writer, request)
+		return
+	}
+
+	configLock.RLock()
+	snapshot := snapshotDashboard()
+	configLock.RUnlock()
+	data := buildDashboard(snapshot)
+
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(writer, data)
+	if err != nil {
+		Error("Could not render dashboard: ", err)
+	}
+}
+
+// snapshotDashboard must be called with the config lock held. Intervals are pinned on the copies as
+// resolving them reads the groups and global config.
+func snapshotDashboard() dashboardSnapshot {
+	snapshot := dashboardSnapshot{
+		servers:      make([]dashboardServer, 0, len(servers)),
+		serverChecks: make([][]dashboardSource, 0, len(servers)),
+		websites:     make([]dashboardSource, 0, len(websites)),
+	}
+	for i := range servers {
+		server := &servers[i]
+		snapshot.servers = append(snapshot.servers, dashboardServer{
+			Name:      server.Name,
+			Host:      server.Host,
+			Connected: server.Session != nil && server.Session.IsConnected(),
+			Checks:    make([]dashboardCheck, 0),
+		})
+		serverCopy := *server
+		serverCopy.Interval = server.GetInterval() / time.Second
+		sources := make([]dashboardSource, 0)
+		for _, check := range server.GetChecks() {
+			check := check
+			check.Interval = check.GetInterval(server) / time.Second
+			sources = append(sources, newDashboardSource(&ServerCheck{Server: &serverCopy, Check: &check}, check.Name))
+		}
+		snapshot.serverChecks = append(snapshot.serverChecks, sources)
+	}
+	for i := range websites {
+		website := websites[i]
+		website.Interval = website.GetInterval() / time.Second
+		snapshot.websites = append(snapshot.websites, newDashboardSource(&WebsiteCheck{Website: &website}, website.Name))
+	}
+
+	return snapshot
+}
+
+func newDashboardSource(checkResult CheckResult, name string) dashboardSource {
+	return dashboardSource{
+		checkResult:    checkResult,
+		name:           name,
+		severityConfig: checkResult.GetSeverity(),
+	}
+}
+
+func buildDashboard(snapshot dashboardSnapshot) dashboardData {
+	states := getAlertStates()
+	data := dashboardData{
+		Generated: time.Now(),
+		Servers:   snapshot.servers,
+		Websites:  make([]dashboardCheck, 0, len(snapshot.websites)),
+		Alerts:    make([]dashboardAlert, 0, len(states)),
+	}
+
+	for testId, state := range states {
+		data.Alerts = append(data.Alerts, dashboardAlert{
+			TestId:         testId,
+			Subject:        state.Subject,
+			Since:          state.Since,
+			EscalationTier: state.EscalationLevel + 1,
+			Acknowledged:   state.IsAcknowledged(),
+			Channels:       state.Channels,
+		})
+	}
+	sort.Slice(data.Alerts, func(i, j int) bool {
+		return data.Alerts[i].Since.Before(data.Alerts[j].Since)
+	})
+
+	for i, sources := range snapshot.serverChecks {
+		for _, source := range sources {
+			data.Servers[i].Checks = append(data.Servers[i].Checks, newDashboardCheck(source, states))
+		}
+	}
+	for _, source := range snapshot.websites {
+		data.Websites = append(data.Websites, newDashboardCheck(source, states))
+	}
+
+	return data
+}
+
+// newDashboardCheck describes the latest result for a check, taken from memory or, after a restart, from
+// the check's history in the database.
+func newDashboardCheck(source dashboardSource, states map[string]alertState) dashboardCheck {
+	checkResult := source.checkResult
+	testId := checkResult.GetTestId()
+	dashboard := dashboardCheck{
+		TestId:            testId,
+		Name:              source.name,
+		Status:            "unknown",
+		FailurePercentage: "-",
+	}
+	if state, ok := states[testId]; ok {
+		dashboard.Alert = &dashboardAlert{
+			TestId:       testId,
+			Subject:      state.Subject,
+			Since:        state.Since,
+			Acknowledged: state.IsAcknowledged(),
+		}
+	}
+
+	latest := getLatestResult(testId)
+	severityConfig := source.severityConfig
+	if severityConfig != nil {
+		failurePercentage, _, err := GetFailurePercentage(checkResult, severityConfig)
+		if err != nil {
+			Error("Failed to get results matching `", testId, "`: ", err)
+		} else {
+			dashboard.FailurePercentage = fmt.Sprintf("%.0f%% over %v", failurePercentage, severityConfig.CheckMinutes*time.Minute)
+		}
+		if latest == nil {
+			history, err := checkResult.GetHistorySince(time.Now().Add(-severityConfig.CheckMinutes * time.Minute))
+			if err == nil && len(*history) > 0 {
+				latest = (*history)[len(*history)-1]
+			}
+		}
+	}
+	if latest == nil {
+		return dashboard
+	}
+
+	dashboard.LastRun = latest.GetTimestamp()
+	dashboard.Status = "failing"
+	if latest.HasPassed() {
+		dashboard.Status = "passing"
+	}
+	switch result := latest.(type) {
+	case *ServerCheck:
+		output := strings.TrimSpace(result.Stdout + "\n" + result.Stderr)
+		if result.FailureReason != "" {
+			output = strings.TrimSpace(fmt.Sprintf("[%v] %v", result.FailureReason, output))
+		}
+		dashboard.Output = output
+	case *WebsiteCheck:
+		if len(result.Errors) > 0 {
+			dashboard.Output = strings.Join(result.Errors, "\n")
+		} else if result.StatusCode != 0 {
+			dashboard.Output = fmt.Sprintf("%d in %.0f ms", result.StatusCode, result.ResponseTimeMS)
+		}
+	}
+
+	return dashboard
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/olivere/elastic"
 	"server-monitor/mapping"
@@ -11,18 +14,36 @@ import (
 type index struct {
 	name    string
 	mapping string
+	// fields is put onto an existing index, mapping fields added since it was created
+	fields string
+	// fieldsCheck is a field from fields, missing from documents stored before it was added
+	fieldsCheck string
 }
 
 var database *elastic.Client
 var ctx = context.Background()
 var indexes []index = []index{
 	{
-		name:    "server_check",
-		mapping: mapping.ServerCheck,
+		name:        "server_check",
+		mapping:     mapping.ServerCheck,
+		fields:      mapping.ServerCheckFields,
+		fieldsCheck: "testId.keyword",
 	},
 	{
-		name:    "alert",
-		mapping: mapping.Alert,
+		name:        "website_check",
+		mapping:     mapping.WebsiteCheck,
+		fields:      mapping.WebsiteCheckFields,
+		fieldsCheck: "testId.keyword",
+	},
+	{
+		name:    "server_connection",
+		mapping: mapping.ServerConnection,
+	},
+	{
+		name:        "alert",
+		mapping:     mapping.Alert,
+		fields:      mapping.AlertFields,
+		fieldsCheck: "alertId.keyword",
 	},
 }
 
@@ -31,6 +52,21 @@ func InitiateDatabase() {
 	__createIndexes()
 }
 
+// CloseDatabase flushes every index so pending writes are persisted, then stops the client.
+func CloseDatabase() {
+	if database == nil {
+		return
+	}
+
+	for _, index := range indexes {
+		_, err := database.Flush().Index(index.name).Do(ctx)
+		if err != nil {
+			Error("Elastic - could not flush index `"+index.name+"`: ", err)
+		}
+	}
+	database.Stop()
+}
+
 func __connect() {
 	host := "127.0.0.1"
 	port := 9200
@@ -82,6 +118,107 @@ func __createIndexes() {
 			if !createResponse.Acknowledged {
 				Info("Index "+index.name+" not Acknowledged 🤷‍♂️", err)
 			}
+		} else if index.fields != "" {
+			__updateIndex(index)
+		}
+	}
+}
+
+// __updateIndex puts the index's fields onto its mapping and re-indexes the documents stored without them, as
+// a new field is only filled in when a document is indexed.
+func __updateIndex(index index) {
+	_, err := database.PutMapping().Index(index.name).Type(index.name).BodyString(index.fields).Do(ctx)
+	if err != nil {
+		Fatal("Elastic - could not update mapping for index "+index.name+": ", err)
+	}
+
+	response, err := database.UpdateByQuery(index.name).
+		Query(elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery(index.fieldsCheck))).
+		ProceedOnVersionConflict().
+		Do(ctx)
+	if err != nil {
+		Fatal("Elastic - could not re-index documents in index "+index.name+": ", err)
+	}
+	if response.Updated > 0 {
+		Info(fmt.Sprintf("Elastic - re-indexed %d documents in index %v", response.Updated, index.name))
+	}
+}
+
+func indexDocument(indexName string, id string, mapping *string) error {
+	if database == nil {
+		return errors.New(fmt.Sprintf("Could not index `%v`: database not connected", indexName))
+	}
+
+	bulkRequest := database.Bulk()
+	req := elastic.NewBulkIndexRequest().
+		Index(indexName).
+		Type(indexName).
+		Id(id).
+		Doc(mapping)
+	bulkRequest = bulkRequest.Add(req)
+	response, err := bulkRequest.Do(ctx)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not process mappings to elastic: %v", err))
+	}
+
+	indexed := make(map[string]int)
+	indexErrors := make([]string, 0)
+	for _, itemRecord := range response.Items {
+		var item *elastic.BulkResponseItem = nil
+		if indexResponse, ok := itemRecord["index"]; ok {
+			item = indexResponse
+		}
+		if item == nil {
+			continue
+		}
+		if item.Error == nil {
+			indexed[item.Index]++
+		} else {
+			indexErrors = append(indexErrors, "`"+item.Index+"` "+item.Id+": "+item.Error.Reason)
 		}
 	}
+	indexCount := 0
+	if count, ok := indexed[indexName]; ok {
+		indexCount = count
+	}
+	Debug("Indexed ", indexCount, " ", indexName)
+	database.Flush().Index(indexName).Do(ctx)
+
+	if len(indexErrors) > 0 {
+		return errors.New(fmt.Sprintf("There were problems indexing `%v`: %v", indexName, strings.Join(indexErrors, ", ")))
+	}
+
+	return nil
+}
+
+// HistoryFilter narrows a search of check results or alerts. Empty fields are not filtered on.
+type HistoryFilter struct {
+	Id     string
+	From   time.Time
+	To     time.Time
+	Passed *bool
+	State  string
+	Size   int
+}
+
+// search runs the filter against an index, newest documents first, matching the id exactly against idField.
+func (filter *HistoryFilter) search(indexName string, idField string) (*elastic.SearchResult, error) {
+	query := elastic.NewBoolQuery()
+	query.Must(elastic.NewRangeQuery("timestamp").From(filter.From).To(filter.To))
+	if filter.Id != "" {
+		query.Must(elastic.NewTermQuery(idField, filter.Id))
+	}
+	if filter.Passed != nil {
+		query.Must(elastic.NewTermQuery("passed", *filter.Passed))
+	}
+	if filter.State != "" {
+		query.Must(elastic.NewTermQuery("state", filter.State))
+	}
+
+	return database.Search().
+		Index(indexName).
+		Query(query).
+		Sort("timestamp", false).
+		From(0).Size(filter.Size).
+		Do(ctx)
 }
@@ -4,15 +4,34 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/olivere/elastic"
 )
 
+type AlertDelivery struct {
+	Channel     string `json:"channel"`
+	Success     bool   `json:"success"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Attempts    int    `json:"attempts"`
+	FallbackFor string `json:"fallbackFor,omitempty"`
+}
+
 type Alert struct {
-	AlertId   string    `json:"alertId"`
-	Timestamp time.Time `json:"timestamp"`
+	AlertId         string          `json:"alertId"`
+	State           string          `json:"state"`
+	Subject         string          `json:"subject,omitempty"`
+	OutageSeconds   float64         `json:"outageSeconds,omitempty"`
+	EscalationLevel int             `json:"escalationLevel"`
+	Suppressed      bool            `json:"suppressed"`
+	SuppressedBy    string          `json:"suppressedBy,omitempty"`
+	ConsequenceOf   string          `json:"consequenceOf,omitempty"`
+	AcknowledgedBy  string          `json:"acknowledgedBy,omitempty"`
+	Comment         string          `json:"comment,omitempty"`
+	ExpiresAt       *time.Time      `json:"expiresAt,omitempty"`
+	Deliveries      []AlertDelivery `json:"deliveries"`
+	Timestamp       time.Time       `json:"timestamp"`
 }
 
 func (alert *Alert) GetId() string {
@@ -40,58 +59,130 @@ func (alert *Alert) GetMapping(setTimestamp bool) (*string, error) {
 }
 
 func (alert *Alert) Save() error {
-	bulkRequest := database.Bulk()
 	mapping, err := alert.GetMapping(true)
 	if err != nil {
 		return err
 	}
-	req := elastic.NewBulkIndexRequest().
+
+	return indexDocument("alert", alert.GetId(), mapping)
+}
+
+func GetAlertsSince(alertId string, timeFrom time.Time) (*[]Alert, error) {
+	query := elastic.NewBoolQuery()
+	query.Must(elastic.NewTermQuery("alertId.keyword", alertId)).
+		Must(elastic.NewRangeQuery("timestamp").From(timeFrom).To(time.Now()))
+	search, err := database.Search().
 		Index("alert").
-		Type("alert").
-		Id(alert.GetId()).
-		Doc(mapping)
-	bulkRequest = bulkRequest.Add(req)
-	response, err := bulkRequest.Do(ctx)
+		Query(query).
+		Sort("timestamp", true).
+		From(0).Size(1000).
+		Do(ctx)
+
 	if err != nil {
-		return errors.New(fmt.Sprintf("Could not process mappings to elastic: %v", err))
-		// Error(bulkRequest)
-	} else {
-		indexed := make(map[string]int)
-		indexErrors := make([]string, 0)
-		for _, itemRecord := range response.Items {
-			var item *elastic.BulkResponseItem = nil
-			if indexResponse, ok := itemRecord["index"]; ok {
-				item = indexResponse
-			}
-			if item == nil {
-				continue
-			}
-			if item.Error == nil {
-				indexed[item.Index]++
-			} else {
-				// errored[item.Index]++
-				indexErrors = append(indexErrors, "`"+item.Index+"` ", item.Id, ": ", item.Error.Reason)
-				// Error("Error for `"+item.Index+"` ", item.Id, ": ", item.Error.Reason)
-			}
+		return nil, errors.New(fmt.Sprintf("Could not get alerts: %v", err))
+	}
+
+	results := make([]Alert, 0)
+	for _, record := range search.Hits.Hits {
+		var result Alert
+		err = json.Unmarshal(*record.Source, &result)
+		if err != nil {
+			Error("Could not deserialise alert json: ", err)
+			continue
 		}
-		// errorCount := len
-		indexCount := 0
-		// if count, ok := errored["alert"]; ok {
-		// 	errorCount = count
-		// }
-		if count, ok := indexed["alert"]; ok {
-			indexCount = count
+
+		results = append(results, result)
+	}
+
+	return &results, nil
+}
+
+// GetLatestAlert returns the most recent unsuppressed alert for the id in one of the given states, or nil.
+func GetLatestAlert(alertId string, states ...string) (*Alert, error) {
+	stateValues := make([]interface{}, 0, len(states))
+	for _, state := range states {
+		stateValues = append(stateValues, state)
+	}
+	query := elastic.NewBoolQuery()
+	query.Must(elastic.NewTermQuery("alertId.keyword", alertId)).
+		Must(elastic.NewTermsQuery("state", stateValues...)).
+		MustNot(elastic.NewTermQuery("suppressed", true))
+	search, err := database.Search().
+		Index("alert").
+		Query(query).
+		Sort("timestamp", false).
+		From(0).Size(1).
+		Do(ctx)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not get latest alert: %v", err))
+	}
+
+	for _, record := range search.Hits.Hits {
+		var result Alert
+		err = json.Unmarshal(*record.Source, &result)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not deserialise alert json: %v", err))
 		}
-		Debug("Indexed ", indexCount, " ", "alert")
-		// if errorCount > 0 {
-		// 	Error(errorCount, " ", "alert", " errors")
-		// }
-		database.Flush().Index("alert").Do(ctx)
-
-		if len(indexErrors) > 0 {
-			return errors.New(fmt.Sprintf("There were problems indexing the alert: %v", strings.Join(indexErrors, ", ")))
+
+		return &result, nil
+	}
+
+	return nil, nil
+}
+
+// GetRecentAlerts returns up to size of the most recent unsuppressed alerts in the given states, oldest first.
+func GetRecentAlerts(size int, states ...string) (*[]Alert, error) {
+	stateValues := make([]interface{}, 0, len(states))
+	for _, state := range states {
+		stateValues = append(stateValues, state)
+	}
+	query := elastic.NewBoolQuery()
+	query.Must(elastic.NewTermsQuery("state", stateValues...)).
+		MustNot(elastic.NewTermQuery("suppressed", true))
+	search, err := database.Search().
+		Index("alert").
+		Query(query).
+		Sort("timestamp", false).
+		From(0).Size(size).
+		Do(ctx)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not get recent alerts: %v", err))
+	}
+
+	results := make([]Alert, len(search.Hits.Hits))
+	count := 0
+	for i := len(search.Hits.Hits) - 1; i >= 0; i-- {
+		err = json.Unmarshal(*search.Hits.Hits[i].Source, &results[count])
+		if err != nil {
+			Error("Could not deserialise alert json: ", err)
+			continue
 		}
+		count++
+	}
+	results = results[:count]
+
+	return &results, nil
+}
+
+func SearchAlerts(filter HistoryFilter) (*[]Alert, error) {
+	search, err := filter.search("alert", "alertId.keyword")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not search alerts: %v", err))
+	}
+
+	results := make([]Alert, 0)
+	for _, record := range search.Hits.Hits {
+		var result Alert
+		err = json.Unmarshal(*record.Source, &result)
+		if err != nil {
+			Error("Could not deserialise alert json: ", err)
+			continue
+		}
+
+		results = append(results, result)
 	}
 
-	return nil
+	return &results, nil
 }
@@ -10,14 +10,30 @@ import (
 	"github.com/olivere/elastic"
 )
 
+const (
+	failureReasonError    = "error"
+	failureReasonTimeout  = "timeout"
+	failureReasonExitCode = "exitCode"
+	failureReasonResponse = "response"
+	failureReasonRegex    = "regex"
+)
+
 type ServerCheck struct {
-	TestId     string        `json:"testId"`
-	ServerName string        `json:"serverName"`
-	CheckName  string        `json:"checkName"`
-	Server     *ServerConfig `json:"-"`
-	Check      *Check        `json:"-"`
-	Passed     bool          `json:"passed"`
-	Timestamp  time.Time     `json:"timestamp"`
+	TestId        string        `json:"testId"`
+	ServerName    string        `json:"serverName"`
+	CheckName     string        `json:"checkName"`
+	Server        *ServerConfig `json:"-"`
+	Check         *Check        `json:"-"`
+	Passed        bool          `json:"passed"`
+	FailureReason string        `json:"failureReason,omitempty"`
+	Stdout        string        `json:"stdout"`
+	Stderr        string        `json:"stderr"`
+	ExitCode      int           `json:"exitCode"`
+	DurationMS    float64       `json:"durationMS"`
+	Timestamp     time.Time     `json:"timestamp"`
+
+	// AlwaysSevere raises an alert on failure without waiting for the severity failure window.
+	AlwaysSevere bool `json:"-"`
 }
 
 func (result *ServerCheck) GetId() string {
@@ -37,6 +53,18 @@ func (result *ServerCheck) GetTestId() string {
 	)
 }
 
+func (result *ServerCheck) GetIdentity() CheckIdentity {
+	identity := CheckIdentity{
+		Server: result.GetServerName(),
+		Check:  result.GetCheckName(),
+	}
+	if result.Check != nil {
+		identity.Group = result.Check.groupName
+	}
+
+	return identity
+}
+
 func (result *ServerCheck) GetServerName() string {
 	if result.Server == nil {
 		return "-"
@@ -73,60 +101,68 @@ func (result *ServerCheck) GetMapping(setTimestamp bool) (*string, error) {
 }
 
 func (result *ServerCheck) Save() error {
-	bulkRequest := database.Bulk()
 	mapping, err := result.GetMapping(true)
 	if err != nil {
 		return err
 	}
-	req := elastic.NewBulkIndexRequest().
-		Index("server_check").
-		Type("server_check").
-		Id(result.GetId()).
-		Doc(mapping)
-	bulkRequest = bulkRequest.Add(req)
-	response, err := bulkRequest.Do(ctx)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Could not process mappings to elastic: %v", err))
-		// Error(bulkRequest)
-	} else {
-		indexed := make(map[string]int)
-		indexErrors := make([]string, 0)
-		for _, itemRecord := range response.Items {
-			var item *elastic.BulkResponseItem = nil
-			if indexResponse, ok := itemRecord["index"]; ok {
-				item = indexResponse
-			}
-			if item == nil {
-				continue
-			}
-			if item.Error == nil {
-				indexed[item.Index]++
-			} else {
-				// errored[item.Index]++
-				indexErrors = append(indexErrors, "`"+item.Index+"` ", item.Id, ": ", item.Error.Reason)
-				// Error("Error for `"+item.Index+"` ", item.Id, ": ", item.Error.Reason)
-			}
-		}
-		// errorCount := len
-		indexCount := 0
-		// if count, ok := errored["server_check"]; ok {
-		// 	errorCount = count
-		// }
-		if count, ok := indexed["server_check"]; ok {
-			indexCount = count
-		}
-		Debug("Indexed ", indexCount, " ", "server_check")
-		// if errorCount > 0 {
-		// 	Error(errorCount, " ", "server_check", " errors")
-		// }
-		database.Flush().Index("server_check").Do(ctx)
-
-		if len(indexErrors) > 0 {
-			return errors.New(fmt.Sprintf("There were problems indexing the result: %v", strings.Join(indexErrors, ", ")))
-		}
+
+	return indexDocument("server_check", result.GetId(), mapping)
+}
+
+func (result *ServerCheck) HasPassed() bool {
+	return result.Passed
+}
+
+func (result *ServerCheck) GetTimestamp() time.Time {
+	return result.Timestamp
+}
+
+func (result *ServerCheck) IsAlwaysSevere() bool {
+	return result.AlwaysSevere
+}
+
+func (result *ServerCheck) GetAlertOverrides() []map[string]bool {
+	overrides := make([]map[string]bool, 0)
+	if result.Check != nil {
+		overrides = append(overrides, result.Check.Alerts)
+	}
+	if result.Server != nil {
+		overrides = append(overrides, result.Server.Alerts)
+	}
+
+	return overrides
+}
+
+func (result *ServerCheck) GetDependsOn() []string {
+	if result.Check == nil {
+		return nil
+	}
+
+	return result.Check.DependsOn
+}
+
+func (result *ServerCheck) GetDetails() []AlertDetail {
+	details := []AlertDetail{
+		{Name: "Server", Value: result.GetServerName()},
+		{Name: "Check", Value: result.GetCheckName()},
+	}
+	if result.FailureReason != "" {
+		details = append(details, AlertDetail{Name: "Failure reason", Value: result.FailureReason})
+	}
+	if result.Check != nil {
+		details = append(details,
+			AlertDetail{Name: "Exit code", Value: fmt.Sprintf("%d", result.ExitCode)},
+			AlertDetail{Name: "Duration", Value: fmt.Sprintf("%.0f ms", result.DurationMS)},
+		)
+	}
+	if strings.TrimSpace(result.Stdout) != "" {
+		details = append(details, AlertDetail{Name: "Output", Value: strings.TrimSpace(result.Stdout)})
+	}
+	if strings.TrimSpace(result.Stderr) != "" {
+		details = append(details, AlertDetail{Name: "Error output", Value: strings.TrimSpace(result.Stderr)})
 	}
 
-	return nil
+	return details
 }
 
 func (checkResult *ServerCheck) GetSeverity() *SeverityConfig {
@@ -158,66 +194,23 @@ func (checkResult *ServerCheck) GetSeverityName() string {
 	return severityName
 }
 
-func (checkResult *ServerCheck) IsSevere() bool {
-	severityConfig := checkResult.GetSeverity()
-
-	if severityConfig == nil {
-		Error(fmt.Sprintf("No severity set for server `%v` or check `%v` - not sending alert", checkResult.GetServerName(), checkResult.GetCheckName()))
-
-		return false
-	}
-
-	timeFrom := time.Now().Add(-severityConfig.CheckMinutes * time.Minute)
-	results, err := checkResult.GetResultsSince(timeFrom)
-	if err != nil {
-		Error("Failed to get results matching `", checkResult.GetTestId(), "`: ", err)
-
-		return true
-	}
-
-	hasOlder := false
-	var failureCount float32 = 0
-	var totalCount float32 = 0
-	for _, result := range *results {
-		if result.Timestamp.Before(timeFrom) {
-			hasOlder = true
-			continue
-		}
-		totalCount++
-		if !result.Passed {
-			failureCount++
-		}
-	}
-
-	if hasOlder && (failureCount/totalCount)*100 > float32(severityConfig.FailedAttemptsPercentage) {
-		return true
-	}
-
-	return false
-}
-
-func (checkResult *ServerCheck) CanResendAlert() bool {
-	severityConfig := checkResult.GetSeverity()
-
-	timeFrom := time.Now().Add(-severityConfig.AlertResendMinutes * time.Minute)
-	results, err := checkResult.GetAlertsSince(timeFrom)
-	if err != nil {
-		Error("Failed to get alerts matching `", checkResult.GetTestId(), "`: ", err)
-
-		return true
+// GetInterval returns how often the check runs. Results are fetched from up to one interval before a window
+// so that whether the window has been fully observed is known for checks that run less often than it.
+func (result *ServerCheck) GetInterval() time.Duration {
+	if result.Server == nil {
+		return getCheckInterval()
 	}
-
-	if len(*results) == 0 {
-		return true
+	if result.Check == nil {
+		return result.Server.GetInterval()
 	}
 
-	return false
+	return result.Check.GetInterval(result.Server)
 }
 
 func (result *ServerCheck) GetResultsSince(timeFrom time.Time) (*[]ServerCheck, error) {
 	query := elastic.NewBoolQuery()
-	query.Must(elastic.NewMatchQuery("testId", result.GetTestId())).
-		Must(elastic.NewRangeQuery("timestamp").From(timeFrom.Add(-1 * time.Minute)).To(time.Now()))
+	query.Must(elastic.NewTermQuery("testId.keyword", result.GetTestId())).
+		Must(elastic.NewRangeQuery("timestamp").From(timeFrom.Add(-result.GetInterval() - time.Minute)).To(time.Now()))
 	search, err := database.Search().
 		Index("server_check").
 		Query(query).
@@ -244,27 +237,32 @@ func (result *ServerCheck) GetResultsSince(timeFrom time.Time) (*[]ServerCheck,
 	return &results, nil
 }
 
-func (result *ServerCheck) GetAlertsSince(timeFrom time.Time) (*[]Alert, error) {
-	query := elastic.NewBoolQuery()
-	query.Must(elastic.NewMatchQuery("alertId", result.GetTestId())).
-		Must(elastic.NewRangeQuery("timestamp").From(timeFrom).To(time.Now()))
-	search, err := database.Search().
-		Index("alert").
-		Query(query).
-		Sort("timestamp", true).
-		From(0).Size(1000).
-		Do(ctx)
+func (result *ServerCheck) GetHistorySince(timeFrom time.Time) (*[]CheckResult, error) {
+	results, err := result.GetResultsSince(timeFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]CheckResult, 0, len(*results))
+	for i := range *results {
+		history = append(history, &(*results)[i])
+	}
 
+	return &history, nil
+}
+
+func SearchServerChecks(filter HistoryFilter) (*[]ServerCheck, error) {
+	search, err := filter.search("server_check", "testId.keyword")
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Could not get result alerts: %v", err))
+		return nil, errors.New(fmt.Sprintf("Could not search server results: %v", err))
 	}
 
-	results := make([]Alert, 0)
+	results := make([]ServerCheck, 0)
 	for _, record := range search.Hits.Hits {
-		var result Alert
+		var result ServerCheck
 		err = json.Unmarshal(*record.Source, &result)
 		if err != nil {
-			Error("Could not deserialise alert json: ", err)
+			Error("Could not deserialise server check json: ", err)
 			continue
 		}
 
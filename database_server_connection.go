@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	connectionStateConnected    = "connected"
+	connectionStateDisconnected = "disconnected"
+)
+
+type ServerConnection struct {
+	ServerName    string    `json:"serverName"`
+	State         string    `json:"state"`
+	PreviousState string    `json:"previousState"`
+	Attempts      int       `json:"attempts"`
+	Error         string    `json:"error,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func (connection *ServerConnection) GetId() string {
+	return fmt.Sprintf(
+		"%v:%v",
+		strings.Replace(connection.ServerName, " ", "-", -1),
+		connection.Timestamp,
+	)
+}
+
+func (connection *ServerConnection) GetMapping(setTimestamp bool) (*string, error) {
+	if setTimestamp {
+		connection.Timestamp = time.Now()
+	}
+
+	bytes, err := json.Marshal(connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := string(bytes)
+
+	return &mapping, nil
+}
+
+func (connection *ServerConnection) Save() error {
+	mapping, err := connection.GetMapping(true)
+	if err != nil {
+		return err
+	}
+
+	return indexDocument("server_connection", connection.GetId(), mapping)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic"
+)
+
+type WebsiteCheck struct {
+	TestId         string         `json:"testId"`
+	CheckName      string         `json:"checkName"`
+	Website        *WebsiteConfig `json:"-"`
+	Passed         bool           `json:"passed"`
+	StatusCode     int            `json:"statusCode"`
+	ResponseTimeMS float64        `json:"responseTimeMS"`
+	Errors         []string       `json:"errors"`
+	Timestamp      time.Time      `json:"timestamp"`
+}
+
+func (result *WebsiteCheck) GetId() string {
+	return fmt.Sprintf(
+		"%v:%v",
+		strings.Replace(result.GetCheckName(), " ", "-", -1),
+		result.Timestamp,
+	)
+}
+
+func (result *WebsiteCheck) GetTestId() string {
+	return strings.Replace(result.GetCheckName(), " ", "-", -1)
+}
+
+func (result *WebsiteCheck) GetIdentity() CheckIdentity {
+	return CheckIdentity{
+		Website: result.GetCheckName(),
+	}
+}
+
+func (result *WebsiteCheck) GetCheckName() string {
+	if result.Website == nil {
+		return "-"
+	}
+
+	return result.Website.Name
+}
+
+func (result *WebsiteCheck) GetMapping(setTimestamp bool) (*string, error) {
+	result.CheckName = result.GetCheckName()
+	result.TestId = result.GetTestId()
+	if setTimestamp {
+		result.Timestamp = time.Now()
+	}
+
+	bytes, err := json.Marshal(result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := string(bytes)
+
+	return &mapping, nil
+}
+
+func (result *WebsiteCheck) Save() error {
+	mapping, err := result.GetMapping(true)
+	if err != nil {
+		return err
+	}
+
+	return indexDocument("website_check", result.GetId(), mapping)
+}
+
+// GetInterval returns how often the website is checked, see ServerCheck.GetInterval.
+func (result *WebsiteCheck) GetInterval() time.Duration {
+	if result.Website == nil {
+		return getCheckInterval()
+	}
+
+	return result.Website.GetInterval()
+}
+
+func (result *WebsiteCheck) GetResultsSince(timeFrom time.Time) (*[]WebsiteCheck, error) {
+	query := elastic.NewBoolQuery()
+	query.Must(elastic.NewTermQuery("testId.keyword", result.GetTestId())).
+		Must(elastic.NewRangeQuery("timestamp").From(timeFrom.Add(-result.GetInterval() - time.Minute)).To(time.Now()))
+	search, err := database.Search().
+		Index("website_check").
+		Query(query).
+		Sort("timestamp", true).
+		From(0).Size(1000).
+		Do(ctx)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not get website results: %v", err))
+	}
+
+	results := make([]WebsiteCheck, 0)
+	for _, record := range search.Hits.Hits {
+		var result WebsiteCheck
+		err = json.Unmarshal(*record.Source, &result)
+		if err != nil {
+			Error("Could not deserialise website check json: ", err)
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	return &results, nil
+}
+
+func (result *WebsiteCheck) HasPassed() bool {
+	return result.Passed
+}
+
+func (result *WebsiteCheck) GetTimestamp() time.Time {
+	return result.Timestamp
+}
+
+func (result *WebsiteCheck) IsAlwaysSevere() bool {
+	return false
+}
+
+func (result *WebsiteCheck) GetAlertOverrides() []map[string]bool {
+	overrides := make([]map[string]bool, 0)
+	if result.Website != nil {
+		overrides = append(overrides, result.Website.Alerts)
+	}
+
+	return overrides
+}
+
+func (result *WebsiteCheck) GetDependsOn() []string {
+	if result.Website == nil {
+		return nil
+	}
+
+	return result.Website.DependsOn
+}
+
+func (result *WebsiteCheck) GetDetails() []AlertDetail {
+	details := []AlertDetail{
+		{Name: "Website", Value: result.GetCheckName()},
+	}
+	if result.Website != nil {
+		details = append(details, AlertDetail{Name: "URL", Value: result.Website.Url})
+	}
+	if result.StatusCode != 0 {
+		details = append(details,
+			AlertDetail{Name: "Status code", Value: fmt.Sprintf("%d", result.StatusCode)},
+			AlertDetail{Name: "Response time", Value: fmt.Sprintf("%.0f ms", result.ResponseTimeMS)},
+		)
+	}
+	if len(result.Errors) > 0 {
+		details = append(details, AlertDetail{Name: "Errors", Value: strings.Join(result.Errors, "\n")})
+	}
+
+	return details
+}
+
+func (result *WebsiteCheck) GetSeverity() *SeverityConfig {
+	if result.Website == nil {
+		return nil
+	}
+
+	return result.Website.Severity()
+}
+
+func (result *WebsiteCheck) GetSeverityName() string {
+	if result.Website == nil {
+		return ""
+	}
+
+	return result.Website.SeverityType
+}
+
+func (result *WebsiteCheck) GetHistorySince(timeFrom time.Time) (*[]CheckResult, error) {
+	results, err := result.GetResultsSince(timeFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]CheckResult, 0, len(*results))
+	for i := range *results {
+		history = append(history, &(*results)[i])
+	}
+
+	return &history, nil
+}
+
+func SearchWebsiteChecks(filter HistoryFilter) (*[]WebsiteCheck, error) {
+	search, err := filter.search("website_check", "testId.keyword")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not search website results: %v", err))
+	}
+
+	results := make([]WebsiteCheck, 0)
+	for _, record := range search.Hits.Hits {
+		var result WebsiteCheck
+		err = json.Unmarshal(*record.Source, &result)
+		if err != nil {
+			Error("Could not deserialise website check json: ", err)
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	return &results, nil
+}
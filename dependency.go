@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	dependencyModeSuppress = "suppress"
+	dependencyModeAnnotate = "annotate"
+)
+
+var latestResults = make(map[string]CheckResult)
+var latestResultsLock sync.Mutex
+
+// recordLatestResult keeps the most recent result for each test so dependent checks can look it up.
+func recordLatestResult(checkResult CheckResult) {
+	latestResultsLock.Lock()
+	defer latestResultsLock.Unlock()
+
+	latestResults[checkResult.GetTestId()] = checkResult
+}
+
+func getLatestResult(testId string) CheckResult {
+	latestResultsLock.Lock()
+	defer latestResultsLock.Unlock()
+
+	return latestResults[testId]
+}
+
+// isFailing reports whether the latest result for the test failed. A server check also counts as failing
+// while its server is not connected, as the check cannot run to report otherwise.
+func isFailing(testId string) bool {
+	result := getLatestResult(testId)
+	if result == nil {
+		return false
+	}
+	if !result.HasPassed() {
+		return true
+	}
+	if serverCheck, ok := result.(*ServerCheck); ok && serverCheck.Server != nil && serverCheck.Check != nil {
+		connection := getLatestResult(getConnectionTestId(serverCheck))
+
+		return connection != nil && !connection.HasPassed()
+	}
+
+	return false
+}
+
+// getFailingDependency returns the test id of the first upstream check the result depends on that is
+// failing, or an empty string. A reference is a test id, e.g. `Server-Name:check-name` or a website name,
+// and a bare server name refers to that server's connection.
+func getFailingDependency(checkResult CheckResult) string {
+	for _, reference := range checkResult.GetDependsOn() {
+		testId := strings.Replace(reference, " ", "-", -1)
+		if getLatestResult(testId) == nil && !strings.Contains(testId, ":") {
+			testId += ":-"
+		}
+		if testId != checkResult.GetTestId() && isFailing(testId) {
+			return testId
+		}
+	}
+
+	return ""
+}
+
+func isDependencyAnnotated() bool {
+	return config.Alerts.DependencyMode == dependencyModeAnnotate
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"time"
+)
+
+// EscalationTier adds alert channels once an alert has been active, and unacknowledged, for AfterMinutes.
+type EscalationTier struct {
+	AfterMinutes time.Duration
+	Alerts       map[string]bool
+}
+
+// getEscalationLevel returns the index of the last tier reached after the alert has been active since the
+// given time, or -1 if the severity has no escalation policy.
+func getEscalationLevel(severityConfig *SeverityConfig, since time.Time) int {
+	if severityConfig == nil {
+		return -1
+	}
+
+	level := -1
+	active := time.Since(since)
+	for i, tier := range severityConfig.Escalation {
+		if active >= tier.AfterMinutes*time.Minute {
+			level = i
+		}
+	}
+
+	return level
+}
+
+// escalationNotifiers returns the enabled notifiers named by the tiers from fromLevel to toLevel inclusive.
+// A channel the check or server explicitly disables is left out.
+func escalationNotifiers(checkResult CheckResult, severityConfig *SeverityConfig, fromLevel int, toLevel int) []Notifier {
+	names := make([]string, 0)
+	for level := fromLevel; level <= toLevel && level < len(severityConfig.Escalation); level++ {
+		if level < 0 {
+			continue
+		}
+		for name, enabled := range severityConfig.Escalation[level].Alerts {
+			if enabled && !containsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	eligible := make([]Notifier, 0)
+	for _, notifier := range eligibleNotifiersByName(names) {
+		if !notifier.Settings().Enabled || isDisabledByOverride(checkResult, notifier.Name()) {
+			continue
+		}
+		eligible = append(eligible, notifier)
+	}
+
+	return eligible
+}
+
+func isDisabledByOverride(checkResult CheckResult, alert string) bool {
+	for _, alerts := range checkResult.GetAlertOverrides() {
+		if val, ok := alerts[alert]; ok {
+			return !val
+		}
+	}
+
+	return false
+}
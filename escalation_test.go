@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEscalationLevel(t *testing.T) {
+	severityConfig := &SeverityConfig{
+		Escalation: []EscalationTier{
+			{AfterMinutes: 0},
+			{AfterMinutes: 15},
+			{AfterMinutes: 60},
+		},
+	}
+	delayed := &SeverityConfig{
+		Escalation: []EscalationTier{
+			{AfterMinutes: 5},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		severityConfig *SeverityConfig
+		active         time.Duration
+		expected       int
+	}{
+		{"no severity", nil, time.Hour, -1},
+		{"no policy", &SeverityConfig{}, time.Hour, -1},
+		{"first tier immediately", severityConfig, 0, 0},
+		{"before second tier", severityConfig, 14 * time.Minute, 0},
+		{"second tier", severityConfig, 20 * time.Minute, 1},
+		{"last tier", severityConfig, 2 * time.Hour, 2},
+		{"before delayed first tier", delayed, time.Minute, -1},
+		{"delayed first tier", delayed, 6 * time.Minute, 0},
+	}
+	for _, test := range tests {
+		if level := getEscalationLevel(test.severityConfig, time.Now().Add(-test.active)); level != test.expected {
+			t.Errorf("%v: expected level %d, got %d", test.name, test.expected, level)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const httpShutdownTimeout = 5 * time.Second
+
+var httpServer *http.Server
+var httpServerLock sync.Mutex
+
+type acknowledgeRequest struct {
+	TestId         string        `json:"testId"`
+	By             string        `json:"by"`
+	Comment        string        `json:"comment"`
+	ExpiresMinutes time.Duration `json:"expiresMinutes"`
+}
+
+type httpError struct {
+	Error string `json:"error"`
+}
+
+func (httpConfig *HttpConfig) GetAddress() string {
+	if httpConfig.Address == "" {
+		return defaultHttpAddress
+	}
+
+	return httpConfig.Address
+}
+
+// restartHttpServer stops any running HTTP server and starts a new one if it is enabled in the config.
+func restartHttpServer() {
+	stopHttpServer()
+	if !config.Http.Enabled {
+		return
+	}
+
+	httpServerLock.Lock()
+	defer httpServerLock.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/alerts/acknowledge", requireToken(handleAcknowledgeAlert))
+	registerApiHandlers(mux)
+	if config.Http.Dashboard {
+		mux.HandleFunc("/", requireToken(handleDashboard))
+	}
+	server := &http.Server{
+		Addr:    config.Http.GetAddress(),
+		Handler: mux,
+	}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			Error("HTTP server on ", server.Addr, " stopped: ", err)
+		}
+	}()
+	httpServer = server
+	Info("Listening for HTTP requests on ", server.Addr)
+}
+
+func stopHttpServer() {
+	httpServerLock.Lock()
+	defer httpServerLock.Unlock()
+
+	if httpServer == nil {
+		return
+	}
+	shutdownContext, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	err := httpServer.Shutdown(shutdownContext)
+	if err != nil {
+		Error("Could not stop HTTP server: ", err)
+	}
+	httpServer = nil
+}
+
+// requireToken rejects requests without the configured token, given as a bearer token or, so the dashboard
+// can be opened in a browser, a `token` query parameter. No token means no authentication.
+func requireToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		configLock.RLock()
+		token := config.Http.Token
+		configLock.RUnlock()
+		if token != "" {
+			provided := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+			if provided == "" {
+				provided = request.URL.Query().Get("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeJsonError(writer, http.StatusUnauthorized, "Invalid or missing token")
+				return
+			}
+		}
+
+		handler(writer, request)
+	}
+}
+
+func writeJson(writer http.ResponseWriter, statusCode int, body interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+	err := json.NewEncoder(writer).Encode(body)
+	if err != nil {
+		Error("Could not write HTTP response: ", err)
+	}
+}
+
+func writeJsonError(writer http.ResponseWriter, statusCode int, message string) {
+	writeJson(writer, statusCode, httpError{Error: message})
+}
+
+func handleAcknowledgeAlert(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", http.MethodPost)
+		writeJsonError(writer, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var body acknowledgeRequest
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		writeJsonError(writer, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if body.TestId == "" || body.By == "" {
+		writeJsonError(writer, http.StatusBadRequest, "`testId` and `by` are required")
+		return
+	}
+
+	alert, err := AcknowledgeAlert(body.TestId, body.By, body.Comment, body.ExpiresMinutes)
+	if err != nil {
+		if _, ok := err.(*noActiveAlertError); ok {
+			writeJsonError(writer, http.StatusNotFound, err.Error())
+		} else {
+			Error("Could not acknowledge alert `", body.TestId, "`: ", err)
+			writeJsonError(writer, http.StatusInternalServerError, "Could not acknowledge alert")
+		}
+		return
+	}
+
+	writeJson(writer, http.StatusOK, alert)
+}
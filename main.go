@@ -1,30 +1,43 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
-
-	"gopkg.in/resty.v1"
 )
 
+// newServerSessions must be called with the config lock held, as the sessions are read by HTTP handlers.
+func newServerSessions() {
+	for i := 0; i < len(servers); i++ {
+		server := &servers[i]
+		server.Session = newSshSession(server)
+	}
+}
+
 func connectToServers() {
 	for i := 0; i < len(servers); i++ {
 		server := &servers[i]
-		session, err := sshConnect(server)
+		err := server.Session.Connect()
 		if err != nil {
 			Error("Failed to connect to '", server.Name, "': ", err.Error())
 		}
-		servers[i].Session = session
 	}
 }
 
 func disconnectAllServers() {
 	if len(servers) > 0 {
 		for i := 0; i < len(servers); i++ {
-			err := servers[i].Session.client.Close()
+			if servers[i].Session == nil {
+				continue
+			}
+			err := servers[i].Session.Close()
 			if err != nil {
 				Error("Could not close SSH session for '", servers[i].Name, "': ", err.Error())
 			}
@@ -32,31 +45,134 @@ func disconnectAllServers() {
 	}
 }
 
-func SendAlerts(serverResult *ServerCheck, subject string, message string) {
-	server := serverResult.Server
-	isSevere := serverResult != nil && serverResult.IsSevere()
-	if !isSevere || (isSevere && !serverResult.CanResendAlert()) {
+var pendingAlerts sync.WaitGroup
+
+// SendAlertsAsync sends alerts in the background, tracking them so config reloads can wait for them to finish.
+func SendAlertsAsync(checkResult CheckResult, subject string, message string) {
+	pendingAlerts.Add(1)
+	go func() {
+		defer pendingAlerts.Done()
+		SendAlerts(checkResult, subject, message)
+	}()
+}
+
+func SendAlerts(checkResult CheckResult, subject string, message string) {
+	isSevere := checkResult != nil && IsSevere(checkResult)
+	if !isSevere {
+		return
+	}
+
+	// Escalation tiers are measured from the first alert, so a new alert starts at the first tier
+	severityConfig := checkResult.GetSeverity()
+	state := getAlertState(checkResult.GetTestId())
+	escalationLevel := getEscalationLevel(severityConfig, time.Now())
+	previousLevel := -1
+	if state != nil {
+		previousLevel = state.EscalationLevel
+		if !state.IsAcknowledged() {
+			escalationLevel = getEscalationLevel(severityConfig, state.Since)
+		} else {
+			escalationLevel = previousLevel
+		}
+	}
+	isEscalating := state != nil && escalationLevel > previousLevel
+	if !isEscalating && !CanResendAlert(checkResult) {
+		if state == nil {
+			// Acknowledged before a restart, keep tracking it so the recovery clears the acknowledgement
+			if acknowledgement := GetActiveAcknowledgement(checkResult.GetTestId()); acknowledgement != nil {
+				markAcknowledged(checkResult.GetTestId(), subject, acknowledgement.Timestamp, acknowledgement.ExpiresAt)
+			}
+		}
+
+		return
+	}
+	if silence := GetSilence(checkResult); silence != "" {
+		Warn(fmt.Sprintf("%v ALERT - %v (suppressed by %v)", checkResult.GetSeverityName(), subject, silence))
+		saveSuppressedAlert(checkResult, silence, "")
+
+		return
+	}
+	if isServerDown(checkResult, nil) {
+		Warn(fmt.Sprintf("%v ALERT - %v (suppressed by %v)", checkResult.GetSeverityName(), subject, suppressedByServerDown))
+		saveSuppressedAlert(checkResult, suppressedByServerDown, getConnectionTestId(checkResult))
+
+		return
+	}
+	consequenceOf := getFailingDependency(checkResult)
+	if consequenceOf != "" {
+		if !isDependencyAnnotated() {
+			Warn(fmt.Sprintf("%v ALERT - %v (suppressed, depends on failing `%v`)", checkResult.GetSeverityName(), subject, consequenceOf))
+			saveSuppressedAlert(checkResult, suppressedByDependency, consequenceOf)
+
+			return
+		}
+		message = fmt.Sprintf("%v\nLikely caused by failing `%v`", message, consequenceOf)
+	}
+	var eligible []Notifier
+	if severityConfig == nil || len(severityConfig.Escalation) == 0 {
+		Error(fmt.Sprintf("%v ALERT - %v", checkResult.GetSeverityName(), subject))
+		eligible = eligibleNotifiers(checkResult)
+	} else if isEscalating {
+		Error(fmt.Sprintf("%v ALERT - %v (escalated to tier %d)", checkResult.GetSeverityName(), subject, escalationLevel+1))
+		eligible = escalationNotifiers(checkResult, severityConfig, previousLevel+1, escalationLevel)
+	} else {
+		Error(fmt.Sprintf("%v ALERT - %v", checkResult.GetSeverityName(), subject))
+		eligible = escalationNotifiers(checkResult, severityConfig, 0, escalationLevel)
+	}
+	pending := pendingAlert{
+		checkResult:     checkResult,
+		subject:         subject,
+		message:         message,
+		notifiers:       eligible,
+		escalationLevel: escalationLevel,
+		consequenceOf:   consequenceOf,
+	}
+	if key := alertDigestKey(checkResult); key != "" {
+		queueAlertDigest(key, pending)
+
 		return
 	}
-	Error(fmt.Sprintf("%v ALERT - %v", serverResult.GetSeverityName(), subject))
-	if config.Alerts.SimplePush.Enabled && server.CanSendAlert("simplePush", config.Alerts.SimplePush.Default) {
-		AlertSimplePush(subject, message)
-	}
-	// if config.Alerts.SimplePush.Enabled {
-	// 	if server.CanSendAlert("simplePush", config.Alerts.SimplePush.Default) {
-	// 		Error("Sending simple push")
-	// 		AlertSimplePush(subject, message)
-	// 	}
-	// }
-	// if config.Alerts.Email.Enabled && CanSendAlert(server, "email", config.Alerts.Email.Default) {
-	// 	AlertEmail(subject, message)
-	// }
-	// if config.Alerts.SMS.Enabled && CanSendAlert(server, "sms", config.Alerts.SMS.Default) {
-	// 	AlertSMS(subject, message)
-	// }
+	deliverAlert(pending)
+}
+
+// deliverAlert notifies the alert's channels and records the alert.
+func deliverAlert(pending pendingAlert) {
+	deliveries := notify(pending.notifiers, pending.checkResult, pending.subject, pending.message)
+	recordAlert(pending, deliveries)
+}
+
+func recordAlert(pending pendingAlert, deliveries []AlertDelivery) {
+	testId := pending.checkResult.GetTestId()
+	channels := deliveredChannels(deliveries)
+	if len(deliveries) > 0 && len(channels) == 0 {
+		Error("Alert for `", testId, "` could not be delivered through any channel")
+	}
+	markAlerting(testId, pending.subject, channels, pending.escalationLevel)
+
+	alert := &Alert{
+		AlertId:         testId,
+		State:           alertStateFiring,
+		Subject:         pending.subject,
+		EscalationLevel: pending.escalationLevel,
+		ConsequenceOf:   pending.consequenceOf,
+		Deliveries:      deliveries,
+	}
+	err := alert.Save()
+	if err != nil {
+		Error("Could not save alert: ", err)
+	}
+}
 
+func saveSuppressedAlert(checkResult CheckResult, suppressedBy string, consequenceOf string) {
+	if HasRecordedSuppression(checkResult, suppressedBy) {
+		return
+	}
 	alert := &Alert{
-		AlertId: serverResult.GetTestId(),
+		AlertId:       checkResult.GetTestId(),
+		State:         alertStateFiring,
+		Suppressed:    true,
+		SuppressedBy:  suppressedBy,
+		ConsequenceOf: consequenceOf,
 	}
 	err := alert.Save()
 	if err != nil {
@@ -64,116 +180,124 @@ func SendAlerts(serverResult *ServerCheck, subject string, message string) {
 	}
 }
 
-func runServerChecks(server *ServerConfig) {
-	server.inProgress = true
+// runCheck runs a single check against a server, returning its result and a description of why it failed.
+func runCheck(server *ServerConfig, check *Check) (*ServerCheck, string) {
+	checkResult := &ServerCheck{
+		Server: server,
+		Check:  check,
+		Passed: true,
+	}
+	fail := func(reason string, text string, parts ...interface{}) (*ServerCheck, string) {
+		checkResult.Passed = false
+		checkResult.FailureReason = reason
 
-	checks := make(map[string]Check, 0)
-	// checks := server.Checks
-	for _, group := range groups {
-		for _, serverGroup := range server.Groups {
-			if group.Name == serverGroup {
-				for _, check := range group.Checks {
-					checks[check.Name] = check
-				}
-				// checks = append(checks, group.Checks...)
-			}
+		return checkResult, fmt.Sprintf(text, parts...)
+	}
+
+	response, err := server.Session.RunCommand(check.Command, check.GetTimeout(server))
+	if response != nil {
+		checkResult.Stdout = response.Stdout
+		checkResult.Stderr = response.Stderr
+		checkResult.ExitCode = response.ExitCode
+		checkResult.DurationMS = response.Duration.Seconds() * 1000
+	}
+	if err != nil {
+		reason := failureReasonError
+		if _, ok := err.(*commandTimeoutError); ok {
+			reason = failureReasonTimeout
 		}
+
+		return fail(reason, "Failed to run check '%s': %s", check.Name, err.Error())
 	}
-	for _, check := range server.Checks {
-		checks[check.Name] = check
+
+	if check.HasExitCodeCondition() {
+		if !check.AcceptsExitCode(response.ExitCode) {
+			return fail(failureReasonExitCode, "'%s' exited with unexpected status %d", check.Name, response.ExitCode)
+		}
+	} else if response.ExitCode != 0 {
+		return fail(failureReasonExitCode, "'%s' exited with status %d: %s", check.Name, response.ExitCode, strings.TrimSpace(response.Stderr))
 	}
-	for _, check := range checks {
-		response, err := server.Session.RunCommand(check.Command)
-		var postCheck func()
-		checkResult := &ServerCheck{
-			Server: server,
-			Check:  &check,
-			Passed: true,
+
+	if check.ResponseContains != "" {
+		if !strings.Contains(response.Stdout, check.ResponseContains) {
+			return fail(failureReasonResponse, "'%s' failed with response: %s", check.Name, response.Stdout)
 		}
-		if err != nil {
-			checkResult.Passed = false
-			postCheck = func() {
-				go SendAlerts(checkResult, fmt.Sprintf("%s (%s)", server.Name, check.Name), fmt.Sprintf("Failed to run check '%s': %s", check.Name, err.Error()))
-			}
-			continue
-		} else if check.ResponseContains != "" {
-			if !strings.Contains(response.String(), check.ResponseContains) {
-				checkResult.Passed = false
-				postCheck = func() {
-					go SendAlerts(checkResult, fmt.Sprintf("%s (%s)", server.Name, check.Name), fmt.Sprintf("'%s' failed with response: %s", check.Name, response.String()))
+	} else if check.Regex != nil && check.Regex.Expression != "" {
+		if check.Regex.Index == nil {
+			Warn("Index for regex not provided for check '", check.Name, "'")
+		} else {
+			regex, _ := regexp.Compile(check.Regex.Expression)
+			result := regex.FindAllStringSubmatch(response.Stdout, -1)
+			errors := make([]string, 0)
+			for _, resultEntry := range result {
+				actualResult := resultEntry[*check.Regex.Index]
+				intVal, _ := strconv.Atoi(actualResult)
+				if check.Regex.GreaterThan != nil && intVal <= *check.Regex.GreaterThan {
+					errors = append(errors, fmt.Sprintf("'%v' is less than '%v': %v", check.Name, *check.Regex.GreaterThan, actualResult))
 				}
-				continue
-			}
-		} else if check.Regex != nil && check.Regex.Expression != "" {
-			if check.Regex.Index == nil {
-				Warn("Index for regex not provided for check '", check.Name, "'")
-			} else {
-				regex, _ := regexp.Compile(check.Regex.Expression)
-				result := regex.FindAllStringSubmatch(response.String(), -1)
-				errors := make([]string, 0)
-				for _, resultEntry := range result {
-					actualResult := resultEntry[*check.Regex.Index]
-					intVal, _ := strconv.Atoi(actualResult)
-					if check.Regex.GreaterThan != nil && intVal <= *check.Regex.GreaterThan {
-						errors = append(errors, fmt.Sprintf("'%v' is less than '%v': %v", check.Name, *check.Regex.GreaterThan, actualResult))
-						checkResult.Passed = false
-					}
-					if check.Regex.LessThan != nil && intVal >= *check.Regex.LessThan {
-						errors = append(errors, fmt.Sprintf("'%v' is greater than '%v': %v", check.Name, *check.Regex.LessThan, actualResult))
-						checkResult.Passed = false
-					}
-					if check.Regex.Equals != "" && resultEntry[*check.Regex.Index] != check.Regex.Equals {
-						errors = append(errors, fmt.Sprintf("'%v' does not equal '%v': %v", check.Name, check.Regex.Equals, actualResult))
-						checkResult.Passed = false
-					}
+				if check.Regex.LessThan != nil && intVal >= *check.Regex.LessThan {
+					errors = append(errors, fmt.Sprintf("'%v' is greater than '%v': %v", check.Name, *check.Regex.LessThan, actualResult))
 				}
-				if !checkResult.Passed {
-					uniqueErrors := make(map[string]bool, 0)
-					finalErrors := make([]string, 0)
-					for _, errorMessage := range errors {
-						if uniqueErrors[errorMessage] != true {
-							uniqueErrors[errorMessage] = true
-							finalErrors = append(finalErrors, errorMessage)
-						}
-					}
-					postCheck = func() {
-						go SendAlerts(checkResult, fmt.Sprintf("%s (%s)", server.Name, check.Name), strings.Join(finalErrors, ", "))
+				if check.Regex.Equals != "" && resultEntry[*check.Regex.Index] != check.Regex.Equals {
+					errors = append(errors, fmt.Sprintf("'%v' does not equal '%v': %v", check.Name, check.Regex.Equals, actualResult))
+				}
+			}
+			if len(errors) > 0 {
+				uniqueErrors := make(map[string]bool, 0)
+				finalErrors := make([]string, 0)
+				for _, errorMessage := range errors {
+					if uniqueErrors[errorMessage] != true {
+						uniqueErrors[errorMessage] = true
+						finalErrors = append(finalErrors, errorMessage)
 					}
 				}
+
+				return fail(failureReasonRegex, "%s", strings.Join(finalErrors, ", "))
 			}
 		}
-		if postCheck != nil {
-			postCheck()
-		}
+	}
+
+	return checkResult, ""
+}
+
+func runServerChecks(server *ServerConfig, checks []Check) {
+	for _, check := range checks {
+		check := check
+		checkResult, message := runCheck(server, &check)
 		if checkResult.Passed {
 			Info(server.Name, " - '", check.Name, "' check passed")
 		} else {
 			Error(server.Name, " - '", check.Name, "' check failed")
 		}
-		err = checkResult.Save()
+		err := checkResult.Save()
 		if err != nil {
 			Error("Could not save result: ", err)
 		}
+		if ShouldIntervene(checkResult) {
+			checkResult, message = remediate(server, &check, checkResult, message)
+		}
+		recordLatestResult(checkResult)
+		if !checkResult.Passed {
+			SendAlertsAsync(checkResult, fmt.Sprintf("%s (%s)", server.Name, check.Name), message)
+		} else {
+			ResolveAlertsAsync(checkResult)
+		}
 	}
-
-	server.inProgress = false
 }
 
 func runWebsiteChecks(website *WebsiteConfig) {
-	website.inProgress = true
-
-	var response *resty.Response
-	var responseError error
-	if website.Method == "" || website.Method == "GET" {
-		response, responseError = httpClient.R().Get(website.Url)
-	} else if website.Method == "POST" {
-		request := httpClient.R()
+	method := strings.ToUpper(website.Method)
+	if method == "" {
+		method = "GET"
+	}
+	request := httpClient.R()
+	if method != "GET" {
 		for header, value := range website.RequestHeaders {
 			request.SetHeader(header, value)
 		}
 		request.SetBody(website.RequestBody)
-		response, responseError = request.Post(website.Url)
 	}
+	response, responseError := request.Execute(method, website.Url)
 
 	errors := make([]string, 0)
 	fail := func(text string, parts ...interface{}) {
@@ -181,7 +305,7 @@ func runWebsiteChecks(website *WebsiteConfig) {
 	}
 
 	if responseError != nil {
-		fail("Failed request: ", responseError.Error())
+		fail("Failed request: %v", responseError.Error())
 	} else {
 		if website.StatusCode != 0 && website.StatusCode != response.StatusCode() {
 			fail("Status code - expected '%v', got '%v'", website.StatusCode, response.Status())
@@ -205,7 +329,17 @@ func runWebsiteChecks(website *WebsiteConfig) {
 		}
 	}
 
-	if len(errors) == 0 {
+	checkResult := &WebsiteCheck{
+		Website: website,
+		Passed:  len(errors) == 0,
+		Errors:  errors,
+	}
+	if responseError == nil {
+		checkResult.StatusCode = response.StatusCode()
+		checkResult.ResponseTimeMS = response.Time().Seconds() * 1000
+	}
+
+	if checkResult.Passed {
 		Info("Website test '", website.Name, "' passed")
 	} else {
 		Error("Website test '", website.Name, "' failed with the following errors: ")
@@ -213,60 +347,160 @@ func runWebsiteChecks(website *WebsiteConfig) {
 			Error("  - ", err)
 		}
 	}
+	err := checkResult.Save()
+	if err != nil {
+		Error("Could not save website result: ", err)
+	}
+	recordLatestResult(checkResult)
+	if !checkResult.Passed {
+		SendAlertsAsync(checkResult, website.Name, strings.Join(errors, ", "))
+	} else {
+		ResolveAlertsAsync(checkResult)
+	}
+}
+
+// handleSignals cancels the returned context on SIGINT or SIGTERM. A second signal exits immediately.
+func handleSignals() context.Context {
+	shutdown, stop := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		InfoBold("Received ", received, ", shutting down...")
+		stop()
+		received = <-signals
+		Fatal("Received ", received, " again, exiting immediately")
+	}()
+
+	return shutdown
+}
+
+// shutdownGracefully waits for running checks and pending alerts, up to the configured deadline, before
+// closing SSH sessions and flushing the database.
+func shutdownGracefully(pool *workerPool) {
+	timeout := config.ShutdownTimeoutSeconds * time.Second
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		pool.Wait()
+		flushAlertDigests()
+		pendingAlerts.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		Info("Running checks and alerts finished")
+	case <-time.After(timeout):
+		Warn("Timed out after ", timeout, " waiting for checks and alerts to finish")
+	}
 
-	website.inProgress = false
+	stopHttpServer()
+	disconnectAllServers()
+	CloseDatabase()
+	InfoBold("Shut down")
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ack" {
+		err := runAckCommand(os.Args[2:])
+		if err != nil {
+			Fatal(err)
+		}
+		return
+	}
+
+	shutdown := handleSignals()
 	CheckConfigChanges()
-	InitiateDatabase()
+	RestoreAlertStates()
+	checkScheduler := newScheduler()
+	pool := newWorkerPool(shutdown, config.MaxConcurrentChecks)
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
 	for {
 		if HasConfigChanges() {
-			for {
-				hasRunning := false
-				for i := 0; i < len(servers); i++ {
-					if servers[i].inProgress {
-						hasRunning = true
-						break
-					}
-				}
-				if hasRunning {
-					continue
-				}
-				for i := 0; i < len(websites); i++ {
-					if websites[i].inProgress {
-						hasRunning = true
-						break
-					}
-				}
-				if !hasRunning {
-					break
-				}
-			}
+			pool.Wait()
+			flushAlertDigests()
+			pendingAlerts.Wait()
 			CheckConfigChanges()
+			if config.MaxConcurrentChecks != pool.Size() {
+				pool = newWorkerPool(shutdown, config.MaxConcurrentChecks)
+			}
 		}
+		now := time.Now()
 		for i := 0; i < len(servers); i++ {
 			server := &servers[i]
-			if server.Session == nil {
-				SendAlerts(&ServerCheck{
+			connected := server.Session.IsConnected()
+			if !connected {
+				pool.Submit("reconnect:"+server.Name, server.Session.Reconnect)
+			}
+			connectionResult := &ServerCheck{
+				Server: server,
+				Passed: connected,
+			}
+			recordLatestResult(connectionResult)
+			if connected {
+				ResolveAlertsAsync(connectionResult)
+			}
+			// Passing connections are saved at the server interval too, so the failure percentage
+			// covers both
+			connectionKey := server.Name + ":connection"
+			if checkScheduler.IsDue(connectionKey, now) {
+				checkScheduler.Schedule(connectionKey, server.GetInterval(), now)
+				checkResult := &ServerCheck{
 					Server: server,
-					Passed: false,
-				}, "Server not connected", "Server not connected, cannot run checks")
+					Passed: connected,
+				}
+				pool.Submit(connectionKey, func() {
+					err := checkResult.Save()
+					if err != nil {
+						Error("Could not save result: ", err)
+					}
+					if !checkResult.Passed {
+						SendAlerts(checkResult, "Server not connected", "Server not connected, cannot run checks")
+					}
+				})
+			}
+			if !connected {
 				continue
 			}
 
-			if !server.inProgress {
-				go runServerChecks(server)
+			if pool.IsRunning("server:" + server.Name) {
+				continue
+			}
+			dueChecks := make([]Check, 0)
+			for _, check := range server.GetChecks() {
+				checkKey := server.Name + ":" + check.Name
+				if checkScheduler.IsDue(checkKey, now) {
+					checkScheduler.Schedule(checkKey, check.GetInterval(server), now)
+					dueChecks = append(dueChecks, check)
+				}
+			}
+			if len(dueChecks) > 0 {
+				pool.Submit("server:"+server.Name, func() {
+					runServerChecks(server, dueChecks)
+				})
 			}
 		}
 		for i := 0; i < len(websites); i++ {
 			website := &websites[i]
-			if !website.inProgress {
-				go runWebsiteChecks(website)
+			websiteKey := "website:" + website.Name
+			if pool.IsRunning(websiteKey) || !checkScheduler.IsDue(websiteKey, now) {
+				continue
 			}
+			checkScheduler.Schedule(websiteKey, website.GetInterval(), now)
+			pool.Submit(websiteKey, func() {
+				runWebsiteChecks(website)
+			})
 		}
-		time.Sleep(config.CheckInterval * time.Second)
-	}
 
-	return
+		select {
+		case <-shutdown.Done():
+			shutdownGracefully(pool)
+			return
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SilenceMatcher selects the alerts a maintenance window or silence applies to. Every non-empty list must
+// contain the alert's value; a matcher with no lists matches everything.
+type SilenceMatcher struct {
+	Servers    []string
+	Groups     []string
+	Checks     []string
+	Websites   []string
+	Severities []string
+}
+
+// MaintenanceWindow mutes matching alerts either between Start and End, or for DurationMinutes after each
+// time Cron fires (optionally bounded by Start and End).
+type MaintenanceWindow struct {
+	SilenceMatcher
+	Name            string
+	Cron            string
+	DurationMinutes time.Duration
+	Start           time.Time
+	End             time.Time
+	schedule        *cronSchedule
+}
+
+// Silence is an ad-hoc mute, e.g. while someone investigates an issue.
+type Silence struct {
+	SilenceMatcher
+	Comment   string
+	CreatedBy string
+	Start     time.Time
+	End       time.Time
+}
+
+type MaintenanceConfig struct {
+	Windows  []MaintenanceWindow
+	Silences []Silence
+}
+
+// CheckIdentity names what a check result belongs to, for matching against silences.
+type CheckIdentity struct {
+	Server  string
+	Group   string
+	Check   string
+	Website string
+}
+
+func (window *MaintenanceWindow) validate() error {
+	if window.Cron == "" {
+		if window.Start.IsZero() || window.End.IsZero() {
+			return errors.New(fmt.Sprintf("Maintenance window `%v` needs either a cron schedule or a start and end", window.Name))
+		}
+
+		return nil
+	}
+
+	if window.DurationMinutes <= 0 {
+		return errors.New(fmt.Sprintf("Maintenance window `%v` needs a duration for its cron schedule", window.Name))
+	}
+	schedule, err := parseCron(window.Cron)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Maintenance window `%v`: %v", window.Name, err))
+	}
+	window.schedule = schedule
+
+	return nil
+}
+
+func (window *MaintenanceWindow) IsActive(now time.Time) bool {
+	if !window.Start.IsZero() && now.Before(window.Start) {
+		return false
+	}
+	if !window.End.IsZero() && !now.Before(window.End) {
+		return false
+	}
+	if window.schedule != nil {
+		return window.schedule.StartedWithin(now, window.DurationMinutes*time.Minute)
+	}
+
+	return window.Cron == ""
+}
+
+func (silence *Silence) IsActive(now time.Time) bool {
+	if !silence.Start.IsZero() && now.Before(silence.Start) {
+		return false
+	}
+
+	return silence.End.IsZero() || now.Before(silence.End)
+}
+
+func (matcher *SilenceMatcher) Matches(identity CheckIdentity, severityName string) bool {
+	return matchesAny(matcher.Servers, identity.Server) &&
+		matchesAny(matcher.Groups, identity.Group) &&
+		matchesAny(matcher.Checks, identity.Check) &&
+		matchesAny(matcher.Websites, identity.Website) &&
+		matchesAny(matcher.Severities, severityName)
+}
+
+func matchesAny(values []string, value string) bool {
+	return len(values) == 0 || containsString(values, value)
+}
+
+// GetSilence returns a description of the active maintenance window or silence muting the check result, or
+// an empty string if its alerts should be sent.
+func GetSilence(checkResult CheckResult) string {
+	now := time.Now()
+	identity := checkResult.GetIdentity()
+	severityName := checkResult.GetSeverityName()
+
+	for i := range maintenance.Windows {
+		window := &maintenance.Windows[i]
+		if window.IsActive(now) && window.Matches(identity, severityName) {
+			return fmt.Sprintf("maintenance window `%v`", window.Name)
+		}
+	}
+	for i := range maintenance.Silences {
+		silence := &maintenance.Silences[i]
+		if silence.IsActive(now) && silence.Matches(identity, severityName) {
+			if silence.Comment != "" {
+				return fmt.Sprintf("silence `%v`", silence.Comment)
+			}
+
+			return "silence"
+		}
+	}
+
+	return ""
+}
@@ -10,8 +10,65 @@ const Alert = `
 		"alert": {
 			"properties": {
 				"alertId": {
+					"type": "text",
+					"fields": {
+						"keyword": {
+							"type": "keyword"
+						}
+					}
+				},
+				"state": {
+					"type": "keyword"
+				},
+				"subject": {
+					"type": "text"
+				},
+				"outageSeconds": {
+					"type": "float"
+				},
+				"escalationLevel": {
+					"type": "integer"
+				},
+				"suppressed": {
+					"type": "boolean"
+				},
+				"suppressedBy": {
+					"type": "text"
+				},
+				"consequenceOf": {
+					"type": "keyword"
+				},
+				"acknowledgedBy": {
+					"type": "keyword"
+				},
+				"comment": {
 					"type": "text"
 				},
+				"expiresAt": {
+					"type": "date"
+				},
+				"deliveries": {
+					"properties": {
+						"channel": {
+							"type": "keyword"
+						},
+						"success": {
+							"type": "boolean"
+						},
+						"statusCode": {
+							"type": "integer"
+						},
+						"error": {
+							"type": "text"
+						},
+						"attempts": {
+							"type": "integer"
+						},
+						"fallbackFor": {
+							"type": "keyword"
+						}
+					}
+				},
 				"timestamp": {
 					"type": "date"
 				}
@@ -19,3 +76,19 @@ const Alert = `
 		}
 	}
 }`
+
+// AlertFields is added to alert indexes created before alerts could be matched on their exact id.
+const AlertFields = `
+{
+	"properties": {
+		"alertId": {
+			"type": "text",
+			"fields": {
+				"keyword": {
+					"type": "keyword"
+				}
+			}
+		}
+	}
+}
+`
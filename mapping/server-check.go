@@ -10,7 +10,12 @@ const ServerCheck = `
 		"server_check": {
 			"properties": {
 				"testId": {
-					"type": "text"
+					"type": "text",
+					"fields": {
+						"keyword": {
+							"type": "keyword"
+						}
+					}
 				},
 				"serverName": {
 					"type": "text"
@@ -21,6 +26,21 @@ const ServerCheck = `
 				"passed": {
 					"type": "boolean"
 				},
+				"failureReason": {
+					"type": "keyword"
+				},
+				"stdout": {
+					"type": "text"
+				},
+				"stderr": {
+					"type": "text"
+				},
+				"exitCode": {
+					"type": "integer"
+				},
+				"durationMS": {
+					"type": "float"
+				},
 				"timestamp": {
 					"type": "date"
 				}
@@ -28,3 +48,19 @@ const ServerCheck = `
 		}
 	}
 }`
+
+// ServerCheckFields is added to server_check indexes created before results could be matched on their exact test id.
+const ServerCheckFields = `
+{
+	"properties": {
+		"testId": {
+			"type": "text",
+			"fields": {
+				"keyword": {
+					"type": "keyword"
+				}
+			}
+		}
+	}
+}
+`
@@ -0,0 +1,33 @@
+package mapping
+
+const ServerConnection = `
+{
+	"settings": {
+		"number_of_shards": 1,
+		"number_of_replicas": 0
+	},
+	"mappings": {
+		"server_connection": {
+			"properties": {
+				"serverName": {
+					"type": "text"
+				},
+				"state": {
+					"type": "keyword"
+				},
+				"previousState": {
+					"type": "keyword"
+				},
+				"attempts": {
+					"type": "integer"
+				},
+				"error": {
+					"type": "text"
+				},
+				"timestamp": {
+					"type": "date"
+				}
+			}
+		}
+	}
+}`
@@ -10,7 +10,12 @@ const WebsiteCheck = `
 		"website_check": {
 			"properties": {
 				"testId": {
-					"type": "text"
+					"type": "text",
+					"fields": {
+						"keyword": {
+							"type": "keyword"
+						}
+					}
 				},
 				"checkName": {
 					"type": "text"
@@ -18,6 +23,15 @@ const WebsiteCheck = `
 				"passed": {
 					"type": "boolean"
 				},
+				"statusCode": {
+					"type": "integer"
+				},
+				"responseTimeMS": {
+					"type": "float"
+				},
+				"errors": {
+					"type": "text"
+				},
 				"timestamp": {
 					"type": "date"
 				}
@@ -25,3 +39,19 @@ const WebsiteCheck = `
 		}
 	}
 }`
+
+// WebsiteCheckFields is added to website_check indexes created before results could be matched on their exact test id.
+const WebsiteCheckFields = `
+{
+	"properties": {
+		"testId": {
+			"type": "text",
+			"fields": {
+				"keyword": {
+					"type": "keyword"
+				}
+			}
+		}
+	}
+}
+`
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultRetryDelay = time.Second
+
+// Notifier delivers alerts over a single channel, e.g. SimplePush, email or a named webhook. Send returns
+// the response status code where the channel has one, or 0.
+type Notifier interface {
+	Name() string
+	Settings() AlertChannelConfig
+	Send(checkResult CheckResult, subject string, message string) (int, error)
+}
+
+var notifiers = make(map[string]Notifier)
+
+func RegisterNotifier(notifier Notifier) {
+	if _, ok := notifiers[notifier.Name()]; ok {
+		Warn("Alert channel `", notifier.Name(), "` is configured more than once - using the last one")
+	}
+	notifiers[notifier.Name()] = notifier
+}
+
+// registerNotifiers rebuilds the notifier registry from the alerts section of the global config.
+func registerNotifiers() {
+	notifiers = make(map[string]Notifier)
+	RegisterNotifier(&simplePushNotifier{config: config.Alerts.SimplePush})
+	RegisterNotifier(&emailNotifier{config: config.Alerts.Email})
+	for name, webhook := range config.Alerts.Webhooks {
+		RegisterNotifier(&webhookNotifier{name: name, config: webhook})
+	}
+}
+
+// eligibleNotifiers returns the enabled notifiers the check result may alert through, ordered by name.
+func eligibleNotifiers(checkResult CheckResult) []Notifier {
+	names := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		names = append(names, name)
+	}
+
+	eligible := make([]Notifier, 0)
+	for _, notifier := range eligibleNotifiersByName(names) {
+		settings := notifier.Settings()
+		if settings.Enabled && CanSendAlert(checkResult, notifier.Name(), settings.Default) {
+			eligible = append(eligible, notifier)
+		}
+	}
+
+	return eligible
+}
+
+// eligibleNotifiersByName returns the registered notifiers with the given names, ordered by name.
+func eligibleNotifiersByName(names []string) []Notifier {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	found := make([]Notifier, 0, len(sorted))
+	for _, name := range sorted {
+		if notifier, ok := notifiers[name]; ok {
+			found = append(found, notifier)
+		} else {
+			Warn("Alert channel `", name, "` does not exist")
+		}
+	}
+
+	return found
+}
+
+// notify fans the alert out to every notifier, waiting until each has finished. A channel that still fails
+// after its retries hands the alert to its fallback channel, if one is configured.
+func notify(eligible []Notifier, checkResult CheckResult, subject string, message string) []AlertDelivery {
+	eligibleNames := make([]string, 0, len(eligible))
+	for _, notifier := range eligible {
+		eligibleNames = append(eligibleNames, notifier.Name())
+	}
+
+	var sending sync.WaitGroup
+	var deliveriesLock sync.Mutex
+	deliveries := make([]AlertDelivery, 0)
+	for _, notifier := range eligible {
+		sending.Add(1)
+		go func(notifier Notifier) {
+			defer sending.Done()
+			delivery := sendWithRetry(notifier, checkResult, subject, message)
+			results := []AlertDelivery{delivery}
+			if !delivery.Success {
+				Error("Could not send ", notifier.Name(), " alert: ", delivery.Error)
+				fallback := getFallbackNotifier(notifier, eligibleNames)
+				if fallback != nil {
+					Warn("Sending ", notifier.Name(), " alert through fallback channel ", fallback.Name())
+					fallbackDelivery := sendWithRetry(fallback, checkResult, subject, message)
+					fallbackDelivery.FallbackFor = notifier.Name()
+					if !fallbackDelivery.Success {
+						Error("Could not send ", fallback.Name(), " alert: ", fallbackDelivery.Error)
+					}
+					results = append(results, fallbackDelivery)
+				}
+			}
+
+			deliveriesLock.Lock()
+			deliveries = append(deliveries, results...)
+			deliveriesLock.Unlock()
+		}(notifier)
+	}
+	sending.Wait()
+
+	return deliveries
+}
+
+// getFallbackNotifier returns the enabled fallback for a notifier, unless that channel is already being sent to.
+func getFallbackNotifier(notifier Notifier, eligibleNames []string) Notifier {
+	name := notifier.Settings().Fallback
+	if name == "" || name == notifier.Name() || containsString(eligibleNames, name) {
+		return nil
+	}
+	fallback, ok := notifiers[name]
+	if !ok {
+		Warn("Fallback channel `", name, "` for ", notifier.Name(), " does not exist")
+		return nil
+	}
+	if !fallback.Settings().Enabled {
+		return nil
+	}
+
+	return fallback
+}
+
+// sendWithRetry retries failed sends with exponential backoff, starting from the channel's retry delay.
+func sendWithRetry(notifier Notifier, checkResult CheckResult, subject string, message string) AlertDelivery {
+	retry := notifier.Settings().Retry
+	attempts := retry.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	delay := retry.DelaySeconds * time.Second
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	maxDelay := retry.MaxDelaySeconds * time.Second
+
+	delivery := AlertDelivery{
+		Channel: notifier.Name(),
+	}
+	for attempt := 1; attempt <= attempts; attempt++ {
+		delivery.Attempts = attempt
+		statusCode, err := notifier.Send(checkResult, subject, message)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			delivery.Success = true
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+		if attempt < attempts {
+			Warn("Attempt ", attempt, " of ", attempts, " to send ", notifier.Name(), " alert failed: ", err)
+			time.Sleep(delay)
+			delay *= 2
+			if maxDelay > 0 && delay > maxDelay {
+				delay = maxDelay
+			}
+		}
+	}
+
+	return delivery
+}
+
+// deliveredChannels returns the channels that accepted the alert.
+func deliveredChannels(deliveries []AlertDelivery) []string {
+	channels := make([]string, 0)
+	for _, delivery := range deliveries {
+		if delivery.Success {
+			channels = append(channels, delivery.Channel)
+		}
+	}
+
+	return channels
+}
+
+type simplePushNotifier struct {
+	config SimplePushAlert
+}
+
+func (notifier *simplePushNotifier) Name() string {
+	return "simplePush"
+}
+
+func (notifier *simplePushNotifier) Settings() AlertChannelConfig {
+	return notifier.config.AlertChannelConfig
+}
+
+func (notifier *simplePushNotifier) Send(checkResult CheckResult, subject string, message string) (int, error) {
+	return AlertSimplePush(notifier.config, subject, message)
+}
+
+type emailNotifier struct {
+	config EmailAlert
+}
+
+func (notifier *emailNotifier) Name() string {
+	return "email"
+}
+
+func (notifier *emailNotifier) Settings() AlertChannelConfig {
+	return notifier.config.AlertChannelConfig
+}
+
+func (notifier *emailNotifier) Send(checkResult CheckResult, subject string, message string) (int, error) {
+	return 0, AlertEmail(notifier.config, checkResult, subject, message)
+}
+
+type webhookNotifier struct {
+	name   string
+	config WebhookAlert
+}
+
+func (notifier *webhookNotifier) Name() string {
+	return notifier.name
+}
+
+func (notifier *webhookNotifier) Settings() AlertChannelConfig {
+	return notifier.config.AlertChannelConfig
+}
+
+func (notifier *webhookNotifier) Send(checkResult CheckResult, subject string, message string) (int, error) {
+	return AlertWebhook(notifier.name, notifier.config, checkResult, subject, message)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRemediationMaxAttempts = 1
+const defaultRemediationWindow = 60 * time.Minute
+const defaultRemediationRecheckDelay = 10 * time.Second
+
+var remediationHistory = make(map[string][]time.Time)
+var remediationHistoryLock sync.Mutex
+
+// ShouldIntervene reports whether a failing check has crossed its severity's intervention percentage.
+func ShouldIntervene(checkResult *ServerCheck) bool {
+	if checkResult.Passed || checkResult.Check == nil || checkResult.Check.Remediation == nil {
+		return false
+	}
+	severityConfig := checkResult.GetSeverity()
+	if severityConfig == nil || severityConfig.InterventionPercentage <= 0 {
+		return false
+	}
+
+	failurePercentage, hasHistory, err := GetFailurePercentage(checkResult, severityConfig)
+	if err != nil {
+		Error("Failed to get results matching `", checkResult.GetTestId(), "`: ", err)
+
+		return false
+	}
+
+	return hasHistory && failurePercentage > float32(severityConfig.InterventionPercentage)
+}
+
+// allowRemediation applies the remediation's rate limit, recording the attempt if it is allowed.
+func allowRemediation(testId string, remediation *Remediation) bool {
+	maxAttempts := remediation.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRemediationMaxAttempts
+	}
+	window := remediation.WindowMinutes * time.Minute
+	if window <= 0 {
+		window = defaultRemediationWindow
+	}
+
+	remediationHistoryLock.Lock()
+	defer remediationHistoryLock.Unlock()
+
+	now := time.Now()
+	attempts := make([]time.Time, 0)
+	for _, attempt := range remediationHistory[testId] {
+		if now.Sub(attempt) < window {
+			attempts = append(attempts, attempt)
+		}
+	}
+	if len(attempts) >= maxAttempts {
+		remediationHistory[testId] = attempts
+		return false
+	}
+	remediationHistory[testId] = append(attempts, now)
+
+	return true
+}
+
+// remediate runs the check's remediation command over the server's session and then re-runs the check,
+// returning the new result and failure message. The original result is returned if remediation is skipped.
+func remediate(server *ServerConfig, check *Check, checkResult *ServerCheck, message string) (*ServerCheck, string) {
+	remediation := check.Remediation
+	if !allowRemediation(checkResult.GetTestId(), remediation) {
+		Warn(server.Name, " - remediation for '", check.Name, "' skipped, rate limit reached")
+
+		return checkResult, message
+	}
+
+	InfoBold(server.Name, " - running remediation for '", check.Name, "': ", remediation.Command)
+	timeout := remediation.TimeoutSeconds * time.Second
+	if timeout <= 0 {
+		timeout = check.GetTimeout(server)
+	}
+	response, err := server.Session.RunCommand(remediation.Command, timeout)
+	if err != nil {
+		Error(server.Name, " - remediation for '", check.Name, "' failed: ", err)
+	} else {
+		if output := strings.TrimSpace(response.Stdout); output != "" {
+			Info(server.Name, " - remediation output: ", output)
+		}
+		if output := strings.TrimSpace(response.Stderr); output != "" {
+			Warn(server.Name, " - remediation error output: ", output)
+		}
+		if response.ExitCode != 0 {
+			Error(server.Name, " - remediation for '", check.Name, "' exited with status ", response.ExitCode)
+		}
+	}
+
+	delay := remediation.RecheckDelaySeconds * time.Second
+	if delay <= 0 {
+		delay = defaultRemediationRecheckDelay
+	}
+	time.Sleep(delay)
+
+	recheckResult, recheckMessage := runCheck(server, check)
+	if recheckResult.Passed {
+		Info(server.Name, " - '", check.Name, "' check passed after remediation")
+	} else {
+		Error(server.Name, " - '", check.Name, "' check still failing after remediation")
+		recheckMessage = fmt.Sprintf("%s (remediation attempted)", recheckMessage)
+	}
+	err = recheckResult.Save()
+	if err != nil {
+		Error("Could not save result: ", err)
+	}
+
+	return recheckResult, recheckMessage
+}
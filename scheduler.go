@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+)
+
+const schedulerTick = time.Second
+
+// scheduler tracks when each scheduled job (a server check, website or connection check) should next run.
+type scheduler struct {
+	nextRun map[string]time.Time
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		nextRun: make(map[string]time.Time),
+	}
+}
+
+// IsDue reports whether the job has never run or its next run time has passed.
+func (checkScheduler *scheduler) IsDue(key string, now time.Time) bool {
+	nextRun, ok := checkScheduler.nextRun[key]
+
+	return !ok || !now.Before(nextRun)
+}
+
+func (checkScheduler *scheduler) Schedule(key string, interval time.Duration, now time.Time) {
+	checkScheduler.nextRun[key] = now.Add(interval)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerIsDue(t *testing.T) {
+	checkScheduler := newScheduler()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if !checkScheduler.IsDue("web:disk", now) {
+		t.Fatal("a job that has never run should be due")
+	}
+	checkScheduler.Schedule("web:disk", time.Minute, now)
+	if checkScheduler.IsDue("web:disk", now.Add(59*time.Second)) {
+		t.Fatal("job should not be due before its interval has passed")
+	}
+	if !checkScheduler.IsDue("web:disk", now.Add(time.Minute)) {
+		t.Fatal("job should be due once its interval has passed")
+	}
+	if !checkScheduler.IsDue("web:load", now) {
+		t.Fatal("scheduling one job should not affect another")
+	}
+}
+
+func TestSchedulerReschedule(t *testing.T) {
+	checkScheduler := newScheduler()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	checkScheduler.Schedule("website:shop", 5*time.Minute, now)
+	later := now.Add(5 * time.Minute)
+	checkScheduler.Schedule("website:shop", 10*time.Second, later)
+	if checkScheduler.IsDue("website:shop", later.Add(9*time.Second)) {
+		t.Fatal("rescheduling should replace the previous run time")
+	}
+	if !checkScheduler.IsDue("website:shop", later.Add(10*time.Second)) {
+		t.Fatal("job should be due at its rescheduled time")
+	}
+}
@@ -4,46 +4,591 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
+const (
+	sshAuthPublicKey = "publicKey"
+	sshAuthAgent     = "agent"
+	sshAuthPassword  = "password"
+)
+
+var knownHostsLock sync.Mutex
+
+// hostKeyAlert is a host key alert held back while the config reloads.
+type hostKeyAlert struct {
+	checkResult *ServerCheck
+	subject     string
+	message     string
+}
+
+var heldHostKeyAlerts []hostKeyAlert
+var holdingHostKeyAlerts bool
+var hostKeyAlertsLock sync.Mutex
+
 type sshSession struct {
 	client *ssh.Client
 	server *ServerConfig
+
+	lock          sync.Mutex
+	state         string
+	attempts      int
+	nextAttempt   time.Time
+	closed        bool
+	stopKeepAlive chan struct{}
+}
+
+func newSshSession(server *ServerConfig) *sshSession {
+	return &sshSession{
+		server: server,
+	}
 }
 
-func sshConnect(server *ServerConfig) (*sshSession, error) {
-	hostKey := ssh.InsecureIgnoreHostKey()
+func sshConnect(server *ServerConfig) (*ssh.Client, error) {
+	authMethods, cleanup, err := sshAuthMethods(server)
+	if err != nil {
+		return nil, errors.New("Could not set up authentication for " + server.Host + ": " + err.Error())
+	}
+	defer cleanup()
+
+	hostKey, err := sshHostKeyCallback(server)
+	if err != nil {
+		return nil, errors.New("Could not set up host key verification for " + server.Host + ": " + err.Error())
+	}
+	timeout := sshConnectTimeout()
 	config := &ssh.ClientConfig{
-		User: server.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(server.Password),
-		},
+		User:            server.Username,
+		Auth:            authMethods,
 		HostKeyCallback: hostKey,
+		Timeout:         timeout,
+	}
+	address := net.JoinHostPort(server.Host, fmt.Sprint(server.Port))
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, errors.New("Could not connect to " + server.Host + ": " + err.Error())
 	}
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", server.Host, server.Port), config)
+	// The timeout also covers the handshake, so a host that accepts the connection but never answers
+	// cannot hold on to a worker either
+	conn.SetDeadline(time.Now().Add(timeout))
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
 	if err != nil {
+		conn.Close()
 		return nil, errors.New("Could not connect to " + server.Host + ": " + err.Error())
 	}
+	conn.SetDeadline(time.Time{})
 
-	return &sshSession{
-		client: client,
-		server: server,
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+func sshConnectTimeout() time.Duration {
+	timeout := config.Ssh.ConnectTimeoutSeconds * time.Second
+	if timeout <= 0 {
+		return 10 * time.Second
+	}
+
+	return timeout
+}
+
+// Connect dials the server and, on success, starts watching the connection with keepalive requests.
+// Failed attempts push the next reconnection attempt back with exponential backoff.
+func (serverSession *sshSession) Connect() error {
+	client, err := sshConnect(serverSession.server)
+
+	serverSession.lock.Lock()
+	if serverSession.closed {
+		serverSession.lock.Unlock()
+		if client != nil {
+			client.Close()
+		}
+
+		return errors.New("Session for " + serverSession.server.Host + " has been closed")
+	}
+	serverSession.attempts++
+	attempts := serverSession.attempts
+	if err != nil {
+		serverSession.nextAttempt = time.Now().Add(sshReconnectDelay(attempts))
+		transition := serverSession.setState(connectionStateDisconnected, err)
+		serverSession.lock.Unlock()
+		saveConnectionTransition(transition)
+
+		return err
+	}
+	serverSession.client = client
+	serverSession.attempts = 0
+	serverSession.stopKeepAlive = make(chan struct{})
+	go serverSession.keepAlive(client, serverSession.stopKeepAlive)
+	transition := serverSession.setState(connectionStateConnected, nil)
+	if transition != nil {
+		transition.Attempts = attempts
+	}
+	serverSession.lock.Unlock()
+	saveConnectionTransition(transition)
+
+	return nil
+}
+
+// Reconnect attempts to connect again if the session is down and its backoff has elapsed.
+func (serverSession *sshSession) Reconnect() {
+	if serverSession == nil {
+		return
+	}
+
+	serverSession.lock.Lock()
+	if serverSession.closed || serverSession.client != nil || time.Now().Before(serverSession.nextAttempt) {
+		serverSession.lock.Unlock()
+		return
+	}
+	serverSession.lock.Unlock()
+
+	Info("Reconnecting to '", serverSession.server.Name, "'...")
+	err := serverSession.Connect()
+	if err != nil {
+		Error("Failed to reconnect to '", serverSession.server.Name, "': ", err.Error())
+	} else {
+		Info("Reconnected to '", serverSession.server.Name, "'")
+	}
+}
+
+func (serverSession *sshSession) IsConnected() bool {
+	if serverSession == nil {
+		return false
+	}
+
+	serverSession.lock.Lock()
+	defer serverSession.lock.Unlock()
+
+	return serverSession.client != nil
+}
+
+func (serverSession *sshSession) Close() error {
+	serverSession.lock.Lock()
+	defer serverSession.lock.Unlock()
+
+	serverSession.closed = true
+	if serverSession.client == nil {
+		return nil
+	}
+	close(serverSession.stopKeepAlive)
+	client := serverSession.client
+	serverSession.client = nil
+
+	return client.Close()
+}
+
+// keepAlive watches a connected client until it is closed, dropping it if a keepalive request goes unanswered.
+func (serverSession *sshSession) keepAlive(client *ssh.Client, stop chan struct{}) {
+	interval := config.Ssh.KeepAliveSeconds * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- client.Wait()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case err := <-closed:
+			if err == nil {
+				err = errors.New("Connection closed")
+			}
+			serverSession.disconnected(client, err)
+			return
+		case <-ticker.C:
+			replied := make(chan error, 1)
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				replied <- err
+			}()
+			var err error
+			select {
+			case err = <-replied:
+			case <-time.After(interval):
+				err = errors.New("Keepalive timed out")
+			}
+			if err != nil {
+				client.Close()
+				serverSession.disconnected(client, err)
+				return
+			}
+		}
+	}
+}
+
+func (serverSession *sshSession) disconnected(client *ssh.Client, err error) {
+	serverSession.lock.Lock()
+	if serverSession.client != client {
+		serverSession.lock.Unlock()
+		return
+	}
+	serverSession.client = nil
+	serverSession.nextAttempt = time.Now()
+	transition := serverSession.setState(connectionStateDisconnected, err)
+	serverSession.lock.Unlock()
+
+	Error("Lost connection to '", serverSession.server.Name, "': ", err.Error())
+	saveConnectionTransition(transition)
+}
+
+// setState must be called with the session lock held. It returns the transition to record, or nil if
+// the state has not changed.
+func (serverSession *sshSession) setState(state string, err error) *ServerConnection {
+	if serverSession.state == state {
+		return nil
+	}
+
+	transition := &ServerConnection{
+		ServerName:    serverSession.server.Name,
+		State:         state,
+		PreviousState: serverSession.state,
+		Attempts:      serverSession.attempts,
+	}
+	if err != nil {
+		transition.Error = err.Error()
+	}
+	serverSession.state = state
+
+	return transition
+}
+
+func saveConnectionTransition(transition *ServerConnection) {
+	if transition == nil {
+		return
+	}
+
+	err := transition.Save()
+	if err != nil {
+		Error("Could not save connection state: ", err)
+	}
+}
+
+func sshReconnectDelay(attempts int) time.Duration {
+	minDelay := config.Ssh.ReconnectMinSeconds * time.Second
+	if minDelay <= 0 {
+		minDelay = 5 * time.Second
+	}
+	maxDelay := config.Ssh.ReconnectMaxSeconds * time.Second
+	if maxDelay <= 0 {
+		maxDelay = 5 * time.Minute
+	}
+
+	delay := minDelay
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sshAuthMethods builds the authentication methods for a server in the order they should be tried.
+// The returned cleanup function releases the agent connection once the handshake has completed.
+func sshAuthMethods(server *ServerConfig) ([]ssh.AuthMethod, func(), error) {
+	cleanups := make([]func(), 0)
+	cleanup := func() {
+		for _, method := range cleanups {
+			method()
+		}
+	}
+
+	methodNames := server.AuthMethods
+	if len(methodNames) == 0 {
+		methodNames = make([]string, 0)
+		if server.PrivateKeyPath != "" {
+			methodNames = append(methodNames, sshAuthPublicKey)
+		}
+		if server.UseAgent || server.AgentSocket != "" {
+			methodNames = append(methodNames, sshAuthAgent)
+		}
+		if server.Password != "" || len(methodNames) == 0 {
+			methodNames = append(methodNames, sshAuthPassword)
+		}
+	}
+
+	authMethods := make([]ssh.AuthMethod, 0)
+	for _, methodName := range methodNames {
+		switch methodName {
+		case sshAuthPublicKey:
+			signer, err := sshPrivateKeySigner(server)
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			authMethods = append(authMethods, ssh.PublicKeys(signer))
+		case sshAuthAgent:
+			socket := server.AgentSocket
+			if socket == "" {
+				socket = os.Getenv("SSH_AUTH_SOCK")
+			}
+			if socket == "" {
+				Warn("SSH agent requested for `", server.Name, "` but no agent socket is available")
+				continue
+			}
+			connection, err := net.Dial("unix", socket)
+			if err != nil {
+				Warn("Could not connect to SSH agent for `", server.Name, "`: ", err)
+				continue
+			}
+			cleanups = append(cleanups, func() {
+				connection.Close()
+			})
+			authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(connection).Signers))
+		case sshAuthPassword:
+			authMethods = append(authMethods, ssh.Password(server.Password))
+		default:
+			cleanup()
+			return nil, nil, errors.New(fmt.Sprintf("Unknown authentication method `%v`", methodName))
+		}
+	}
+
+	if len(authMethods) == 0 {
+		cleanup()
+		return nil, nil, errors.New("No usable authentication methods")
+	}
+
+	return authMethods, cleanup, nil
+}
+
+// sshHostKeyCallback verifies host keys against the server's pinned fingerprints, or failing that the
+// configured known_hosts file. Unknown hosts are recorded when trust-on-first-use is enabled.
+func sshHostKeyCallback(server *ServerConfig) (ssh.HostKeyCallback, error) {
+	if len(server.HostKeyFingerprints) > 0 {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			for _, pinned := range server.HostKeyFingerprints {
+				if pinned == fingerprint || pinned == ssh.FingerprintLegacyMD5(key) {
+					return nil
+				}
+			}
+			err := errors.New(fmt.Sprintf("Host key %v does not match any pinned fingerprint", fingerprint))
+			reportHostKeyChanged(server, fingerprint, err)
+
+			return err
+		}, nil
+	}
+
+	knownHostsFile := config.Ssh.KnownHostsFile
+	if knownHostsFile == "" {
+		Warn("No known_hosts file or pinned fingerprints for `", server.Name, "` - host key will not be verified")
+
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if _, err := os.Stat(knownHostsFile); os.IsNotExist(err) && config.Ssh.TrustOnFirstUse {
+		err = os.MkdirAll(filepath.Dir(knownHostsFile), 0700)
+		if err != nil {
+			return nil, err
+		}
+		err = ioutil.WriteFile(knownHostsFile, []byte{}, 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsLock.Lock()
+		defer knownHostsLock.Unlock()
+
+		callback, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			return errors.New("Could not load known_hosts: " + err.Error())
+		}
+
+		err = callback(hostname, remote, key)
+		keyError, ok := err.(*knownhosts.KeyError)
+		if !ok {
+			return err
+		}
+
+		fingerprint := ssh.FingerprintSHA256(key)
+		if len(keyError.Want) > 0 {
+			reportHostKeyChanged(server, fingerprint, err)
+
+			return err
+		}
+		if !config.Ssh.TrustOnFirstUse {
+			return errors.New(fmt.Sprintf("Host key %v is not known", fingerprint))
+		}
+
+		Warn("Trusting new host key for `", server.Name, "`: ", fingerprint)
+		file, err := os.OpenFile(knownHostsFile, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return errors.New("Could not record host key: " + err.Error())
+		}
+		defer file.Close()
+		addresses := []string{knownhosts.Normalize(hostname)}
+		if remoteAddress := knownhosts.Normalize(remote.String()); remoteAddress != addresses[0] {
+			addresses = append(addresses, remoteAddress)
+		}
+		_, err = file.WriteString(knownhosts.Line(addresses, key) + "\n")
+		if err != nil {
+			return errors.New("Could not record host key: " + err.Error())
+		}
+
+		return nil
 	}, nil
 }
 
-func (serverSession *sshSession) RunCommand(command string) (*bytes.Buffer, error) {
-	session, err := serverSession.client.NewSession()
+func reportHostKeyChanged(server *ServerConfig, fingerprint string, err error) {
+	Error("Host key for `", server.Name, "` has changed: ", err)
+	checkResult := &ServerCheck{
+		Server:       server,
+		Check:        &Check{Name: "host key"},
+		Passed:       false,
+		AlwaysSevere: true,
+	}
+	saveErr := checkResult.Save()
+	if saveErr != nil {
+		Error("Could not save result: ", saveErr)
+	}
+	alert := hostKeyAlert{
+		checkResult: checkResult,
+		subject:     fmt.Sprintf("%s (host key changed)", server.Name),
+		message:     fmt.Sprintf("Host key for %s (%s) changed to %s - refusing to connect: %s", server.Name, server.Host, fingerprint, strings.TrimSpace(err.Error())),
+	}
+
+	hostKeyAlertsLock.Lock()
+	if holdingHostKeyAlerts {
+		heldHostKeyAlerts = append(heldHostKeyAlerts, alert)
+		hostKeyAlertsLock.Unlock()
+		return
+	}
+	hostKeyAlertsLock.Unlock()
+	SendAlertsAsync(alert.checkResult, alert.subject, alert.message)
+}
+
+// holdHostKeyAlerts keeps host key alerts raised while connecting during a config reload until
+// releaseHostKeyAlerts, as sending one reads the config that is still being loaded.
+func holdHostKeyAlerts() {
+	hostKeyAlertsLock.Lock()
+	defer hostKeyAlertsLock.Unlock()
+
+	holdingHostKeyAlerts = true
+}
+
+func releaseHostKeyAlerts() {
+	hostKeyAlertsLock.Lock()
+	alerts := heldHostKeyAlerts
+	heldHostKeyAlerts = nil
+	holdingHostKeyAlerts = false
+	hostKeyAlertsLock.Unlock()
+
+	for _, alert := range alerts {
+		SendAlertsAsync(alert.checkResult, alert.subject, alert.message)
+	}
+}
+
+func sshPrivateKeySigner(server *ServerConfig) (ssh.Signer, error) {
+	if server.PrivateKeyPath == "" {
+		return nil, errors.New("Private key path not provided")
+	}
+
+	key, err := ioutil.ReadFile(server.PrivateKeyPath)
+	if err != nil {
+		return nil, errors.New("Could not read private key: " + err.Error())
+	}
+
+	var signer ssh.Signer
+	if server.PrivateKeyPassphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(server.PrivateKeyPassphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, errors.New("Could not parse private key: " + err.Error())
+	}
+
+	return signer, nil
+}
+
+type commandTimeoutError struct {
+	timeout time.Duration
+}
+
+func (err *commandTimeoutError) Error() string {
+	return fmt.Sprintf("Command timed out after %v", err.timeout)
+}
+
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// RunCommand runs a command in a new session, killing it if it has not finished within the timeout.
+// A timeout of zero waits indefinitely. A non-zero exit status is reported through the result rather
+// than as an error.
+func (serverSession *sshSession) RunCommand(command string, timeout time.Duration) (*CommandResult, error) {
+	serverSession.lock.Lock()
+	client := serverSession.client
+	serverSession.lock.Unlock()
+	if client == nil {
+		return nil, errors.New("Not connected to " + serverSession.server.Host)
+	}
+
+	session, err := client.NewSession()
 	if err != nil {
 		return nil, errors.New("Could not start session for " + serverSession.server.Host + ": " + err.Error())
 	}
+	defer session.Close()
 
-	var response bytes.Buffer
-	session.Stdout = &response
-	if err := session.Run(command); err != nil {
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	startTime := time.Now()
+	if err := session.Start(command); err != nil {
 		return nil, err
 	}
 
-	return &response, nil
+	finished := make(chan error, 1)
+	go func() {
+		finished <- session.Wait()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case err = <-finished:
+	case <-expired:
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+
+		return &CommandResult{Duration: time.Since(startTime)}, &commandTimeoutError{timeout: timeout}
+	}
+
+	result := &CommandResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(startTime),
+	}
+	if exitError, ok := err.(*ssh.ExitError); ok {
+		result.ExitCode = exitError.ExitStatus()
+		err = nil
+	}
+
+	return result, err
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSshReconnectDelay(t *testing.T) {
+	config.Ssh.ReconnectMinSeconds = 4
+	config.Ssh.ReconnectMaxSeconds = 60
+	defer func() {
+		config.Ssh = SshConfig{}
+	}()
+
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, 4 * time.Second},
+		{2, 8 * time.Second},
+		{3, 16 * time.Second},
+		{5, 60 * time.Second},
+		{50, 60 * time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			delay := sshReconnectDelay(test.attempts)
+			if delay < test.max/2 || delay > test.max {
+				t.Fatalf("attempt %d: delay %v not within [%v, %v]", test.attempts, delay, test.max/2, test.max)
+			}
+		}
+	}
+}
+
+func TestSshReconnectDelayDefaults(t *testing.T) {
+	config.Ssh = SshConfig{}
+
+	delay := sshReconnectDelay(1)
+	if delay < 2500*time.Millisecond || delay > 5*time.Second {
+		t.Fatalf("default first delay %v not within [2.5s, 5s]", delay)
+	}
+	delay = sshReconnectDelay(100)
+	if delay < 150*time.Second || delay > 5*time.Minute {
+		t.Fatalf("default maximum delay %v not within [2.5m, 5m]", delay)
+	}
+}
+
+func TestHostKeyAlertsHeldDuringReload(t *testing.T) {
+	server := &ServerConfig{Name: "Test Server", Host: "127.0.0.1"}
+	holdHostKeyAlerts()
+	defer func() {
+		hostKeyAlertsLock.Lock()
+		heldHostKeyAlerts = nil
+		holdingHostKeyAlerts = false
+		hostKeyAlertsLock.Unlock()
+	}()
+
+	reportHostKeyChanged(server, "SHA256:test", errors.New("mismatch"))
+
+	hostKeyAlertsLock.Lock()
+	defer hostKeyAlertsLock.Unlock()
+	if len(heldHostKeyAlerts) != 1 {
+		t.Fatalf("expected 1 held alert, got %d", len(heldHostKeyAlerts))
+	}
+	if heldHostKeyAlerts[0].subject != "Test Server (host key changed)" {
+		t.Fatalf("unexpected subject %q", heldHostKeyAlerts[0].subject)
+	}
+}
+
+func TestSshConnectTimeout(t *testing.T) {
+	config.Ssh = SshConfig{}
+	if timeout := sshConnectTimeout(); timeout != 10*time.Second {
+		t.Fatalf("default timeout %v, expected 10s", timeout)
+	}
+
+	config.Ssh.ConnectTimeoutSeconds = 3
+	defer func() {
+		config.Ssh = SshConfig{}
+	}()
+	if timeout := sshConnectTimeout(); timeout != 3*time.Second {
+		t.Fatalf("timeout %v, expected 3s", timeout)
+	}
+}
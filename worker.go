@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultMaxConcurrentChecks = 10
+
+// workerPool runs jobs in the background with bounded concurrency. Each job has a key and a job is not
+// started while another with the same key is still queued or running.
+type workerPool struct {
+	ctx     context.Context
+	slots   chan struct{}
+	pending sync.WaitGroup
+	lock    sync.Mutex
+	running map[string]bool
+}
+
+func newWorkerPool(ctx context.Context, size int) *workerPool {
+	if size <= 0 {
+		size = defaultMaxConcurrentChecks
+	}
+
+	return &workerPool{
+		ctx:     ctx,
+		slots:   make(chan struct{}, size),
+		running: make(map[string]bool),
+	}
+}
+
+// Submit queues the job, returning false if a job with the same key is already queued or running.
+func (pool *workerPool) Submit(key string, job func()) bool {
+	pool.lock.Lock()
+	if pool.running[key] {
+		pool.lock.Unlock()
+		return false
+	}
+	pool.running[key] = true
+	pool.lock.Unlock()
+
+	pool.pending.Add(1)
+	go func() {
+		defer pool.pending.Done()
+		defer func() {
+			pool.lock.Lock()
+			delete(pool.running, key)
+			pool.lock.Unlock()
+		}()
+
+		select {
+		case pool.slots <- struct{}{}:
+		case <-pool.ctx.Done():
+			return
+		}
+		defer func() {
+			<-pool.slots
+		}()
+
+		if pool.ctx.Err() != nil {
+			return
+		}
+		job()
+	}()
+
+	return true
+}
+
+func (pool *workerPool) IsRunning(key string) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	return pool.running[key]
+}
+
+func (pool *workerPool) Size() int {
+	return cap(pool.slots)
+}
+
+// Wait blocks until every submitted job has finished. Jobs still waiting for a slot when the pool's
+// context is cancelled are dropped.
+func (pool *workerPool) Wait() {
+	pool.pending.Wait()
+}
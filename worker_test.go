@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	pool := newWorkerPool(context.Background(), 3)
+
+	var running, maxRunning int32
+	for i := 0; i < 20; i++ {
+		pool.Submit(fmt.Sprintf("job-%d", i), func() {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				observed := atomic.LoadInt32(&maxRunning)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+	}
+	pool.Wait()
+
+	if maxRunning > 3 {
+		t.Fatalf("expected at most 3 jobs at once, got %d", maxRunning)
+	}
+	if maxRunning < 2 {
+		t.Fatalf("expected jobs to run concurrently, got at most %d at once", maxRunning)
+	}
+}
+
+func TestWorkerPoolDefaultSize(t *testing.T) {
+	if size := newWorkerPool(context.Background(), 0).Size(); size != defaultMaxConcurrentChecks {
+		t.Fatalf("expected default size %d, got %d", defaultMaxConcurrentChecks, size)
+	}
+}
+
+func TestWorkerPoolDeduplicatesKeys(t *testing.T) {
+	pool := newWorkerPool(context.Background(), 2)
+	release := make(chan struct{})
+	var runs int32
+
+	if !pool.Submit("server:web", func() {
+		atomic.AddInt32(&runs, 1)
+		<-release
+	}) {
+		t.Fatal("first submit should be accepted")
+	}
+	if !pool.IsRunning("server:web") {
+		t.Fatal("key should be running after submit")
+	}
+	if pool.Submit("server:web", func() {
+		atomic.AddInt32(&runs, 1)
+	}) {
+		t.Fatal("second submit with the same key should be rejected")
+	}
+	close(release)
+	pool.Wait()
+
+	if pool.IsRunning("server:web") {
+		t.Fatal("key should be released once the job finished")
+	}
+	if runs != 1 {
+		t.Fatalf("expected 1 run, got %d", runs)
+	}
+	if !pool.Submit("server:web", func() {}) {
+		t.Fatal("key should be accepted again once the job finished")
+	}
+	pool.Wait()
+}
+
+func TestWorkerPoolWaitsForJobs(t *testing.T) {
+	pool := newWorkerPool(context.Background(), 4)
+	var finished int32
+	for i := 0; i < 8; i++ {
+		pool.Submit(fmt.Sprintf("job-%d", i), func() {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&finished, 1)
+		})
+	}
+	pool.Wait()
+
+	if finished != 8 {
+		t.Fatalf("expected Wait to return after all 8 jobs, %d finished", finished)
+	}
+}
+
+func TestWorkerPoolDropsQueuedJobsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := newWorkerPool(ctx, 1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var queuedRuns int32
+
+	pool.Submit("running", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	for i := 0; i < 5; i++ {
+		pool.Submit(fmt.Sprintf("queued-%d", i), func() {
+			atomic.AddInt32(&queuedRuns, 1)
+		})
+	}
+	cancel()
+	close(release)
+	pool.Wait()
+
+	if queuedRuns != 0 {
+		t.Fatalf("expected queued jobs to be dropped after cancel, %d ran", queuedRuns)
+	}
+	for i := 0; i < 5; i++ {
+		if pool.IsRunning(fmt.Sprintf("queued-%d", i)) {
+			t.Fatalf("dropped job queued-%d should release its key", i)
+		}
+	}
+}